@@ -3,18 +3,31 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
+	"brick-smart-template/pkg/alerting"
 	"brick-smart-template/pkg/appmanager"
+	"brick-smart-template/pkg/auth"
+	"brick-smart-template/pkg/grpcapi"
 	"brick-smart-template/pkg/httpapi"
+	"brick-smart-template/pkg/policy"
+	"brick-smart-template/pkg/sandbox"
+	"brick-smart-template/pkg/timeseries"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 func main() {
+	// 沙箱初始化进程：应用隔离设置后exec真正的应用，不会返回
+	if sandbox.IsInit() {
+		sandbox.Init()
+	}
+
 	// 解析命令行参数
 	var (
 		httpPort = flag.String("http-port", "", "HTTP API server port (e.g., 8000)")
@@ -37,7 +50,7 @@ func main() {
 	logger.SetLevel(logrus.InfoLevel)
 
 	// 加载配置
-	loadConfig()
+	loadConfig(*configFile)
 
 	// 应用命令行参数
 	if *httpPort != "" {
@@ -46,9 +59,6 @@ func main() {
 	if *grpcPort != "" {
 		viper.Set("grpc.addr", ":"+*grpcPort)
 	}
-	if *configFile != "" {
-		viper.SetConfigFile(*configFile)
-	}
 
 	proxyID := *id
 	if proxyID == "" {
@@ -58,11 +68,107 @@ func main() {
 		proxyID = "default-proxy"
 	}
 
+	// 默认的上报socket位于私有目录中，配置为空字符串时关闭
+	viper.SetDefault("http.report_socket", "auto")
+
+	// 加载安全策略
+	appPolicy, err := policy.Load()
+	if err != nil {
+		logger.Fatalf("Failed to load policy: %v", err)
+	}
+
 	// 创建应用管理器
-	manager := appmanager.NewManager(logger, proxyID)
+	manager, err := appmanager.NewManager(logger, proxyID)
+	if err != nil {
+		logger.Fatalf("Failed to load manifest: %v", err)
+	}
+	// 子进程上报的 device_id 需与proxy ID一致
+	manager.SetProxyEnv("PROXY_ID", proxyID)
+	manager.SetPolicy(appPolicy)
+
+	// 加载内部状态历史
+	dataHistory, err := timeseries.Load(logger)
+	if err != nil {
+		logger.Fatalf("Failed to load data history: %v", err)
+	}
+	manager.SetDataHistory(dataHistory)
+	if err := manager.SetStatsWindows(viper.GetStringSlice("stats.windows")); err != nil {
+		logger.Fatalf("Invalid stats config: %v", err)
+	}
+
+	// 加载告警规则
+	alertEngine, err := alerting.Load(logger)
+	if err != nil {
+		logger.Fatalf("Failed to load alert rules: %v", err)
+	}
+	manager.SetAlerting(alertEngine)
+
+	// 加载API认证配置
+	authenticator, err := auth.Load()
+	if err != nil {
+		logger.Fatalf("Failed to load auth config: %v", err)
+	}
+	if authenticator == nil {
+		logger.Warn("API authentication is disabled")
+	}
 
 	// 创建HTTP服务器
 	httpServer := httpapi.NewServer(manager, logger)
+	httpServer.SetAuthenticator(authenticator)
+	httpServer.SetIdempotencyTTL(viper.GetDuration("http.idempotency_ttl"))
+	httpServer.SetLegacyRouteSchedule(viper.GetTime("http.legacy_routes.deprecated_at"), viper.GetTime("http.legacy_routes.sunset"))
+
+	// 加载TLS配置
+	tlsConfig, err := httpapi.LoadTLSConfig(logger)
+	if err != nil {
+		logger.Fatalf("Failed to load TLS config: %v", err)
+	}
+
+	// 启用TLS时，子进程通过loopback明文接口上报状态
+	if tlsConfig != nil {
+		reportAddr := viper.GetString("http.report_addr")
+		manager.SetProxyEnv("PROXY_REPORT_URL", "http://"+reportAddr+"/v1/app/status/report")
+		go func() {
+			if err := httpServer.RunReport(reportAddr); err != nil {
+				logger.Fatalf("Failed to start status report server: %v", err)
+			}
+		}()
+	}
+
+	// 子进程通过Unix socket上报状态，socket路径通过环境变量传给子进程
+	reportSocket, reportSocketDir, err := resolveReportSocket(viper.GetString("http.report_socket"), proxyID)
+	if err != nil {
+		logger.Fatalf("Failed to prepare status report socket: %v", err)
+	}
+	if reportSocket != "" {
+		manager.SetReportSocket(reportSocket)
+		go func() {
+			if err := httpServer.RunReportSocket(reportSocket); err != nil {
+				logger.Fatalf("Failed to start status report socket: %v", err)
+			}
+		}()
+	}
+	if viper.GetBool("http.disable_public_report") {
+		httpServer.DisablePublicReport()
+	}
+
+	// 创建gRPC服务器，与HTTP API共用认证和TLS配置，grpc.addr为空时不启动
+	var grpcServer *grpcapi.Server
+	if grpcAddr := viper.GetString("grpc.addr"); grpcAddr != "" {
+		_, port, err := net.SplitHostPort(grpcAddr)
+		if err != nil {
+			logger.Fatalf("Invalid gRPC address %q: %v", grpcAddr, err)
+		}
+		manager.SetProxyEnv("PROXY_GRPC_PORT", port)
+
+		grpcServer = grpcapi.NewServer(manager, logger, tlsConfig)
+		grpcServer.SetAuthenticator(authenticator)
+		go func() {
+			if err := grpcServer.Run(grpcAddr); err != nil {
+				logger.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+	}
 
 	// 启动HTTP服务器
 	go func() {
@@ -71,12 +177,30 @@ func main() {
 			httpAddr = ":8000"
 		}
 		
+		if tlsConfig != nil {
+			if err := httpServer.RunTLS(httpAddr, tlsConfig); err != nil {
+				logger.Fatalf("Failed to start HTTPS server: %v", err)
+			}
+			return
+		}
+
 		logger.Infof("Starting HTTP server on %s", httpAddr)
 		if err := httpServer.Run(httpAddr); err != nil {
 			logger.Fatalf("Failed to start HTTP server: %v", err)
 		}
 	}()
 
+	// SIGHUP 重新加载告警规则
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			if _, err := manager.ReloadAlertRules(); err != nil {
+				logger.Errorf("Failed to reload alert rules: %v", err)
+			}
+		}
+	}()
+
 	// 等待中断信号
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -84,9 +208,40 @@ func main() {
 
 	logger.Info("Shutting down...")
 
+	if grpcServer != nil {
+		grpcServer.Shutdown()
+	}
+
+	if reportSocket != "" {
+		os.Remove(reportSocket)
+	}
+	if reportSocketDir != "" {
+		os.Remove(reportSocketDir)
+	}
+
+	if err := dataHistory.Close(); err != nil {
+		logger.Errorf("Failed to persist data history: %v", err)
+	}
+
 	logger.Info("Proxy stopped")
 }
 
+// resolveReportSocket 解析上报socket路径。"auto" 时使用 $XDG_RUNTIME_DIR/brick-proxy-<id>/report.sock，
+// 未设置 XDG_RUNTIME_DIR 时在临时目录下新建随机命名的私有目录，返回需要在退出时删除的目录
+func resolveReportSocket(configured, proxyID string) (string, string, error) {
+	if configured != "auto" {
+		return configured, "", nil
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "brick-proxy-"+proxyID, "report.sock"), "", nil
+	}
+	dir, err := os.MkdirTemp("", "brick-proxy-"+proxyID+"-")
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(dir, "report.sock"), dir, nil
+}
+
 // showHelp 显示帮助信息
 func showHelp() {
 	fmt.Println("Brick Smart Template App Proxy")
@@ -114,10 +269,20 @@ func showHelp() {
 	fmt.Println("  PROXY_HTTP_ADDR=:8080 ./app-proxy")
 }
 
-func loadConfig() {
+func loadConfig(configFile string) {
 	// 设置默认值
 	viper.SetDefault("http.addr", ":8000")
 	viper.SetDefault("grpc.addr", ":50051")
+	viper.SetDefault("http.report_addr", "127.0.0.1:8001")
+	viper.SetDefault("http.idempotency_ttl", "24h")
+	viper.SetDefault("http.legacy_routes.deprecated_at", "2026-10-19T00:00:00Z")
+	viper.SetDefault("http.legacy_routes.sunset", "2027-04-19T00:00:00Z")
+	viper.SetDefault("history.retention", "24h")
+	viper.SetDefault("history.max_points", 100000)
+	viper.SetDefault("history.max_field_points", 10000)
+	viper.SetDefault("history.flush_interval", "30s")
+	viper.SetDefault("stats.windows", appmanager.DefaultStatsWindows)
+	viper.SetDefault("alerts.evaluation_interval", "15s")
 	viper.SetDefault("shutdown.timeout", "30s")
 	viper.SetDefault("log.level", "info")
 
@@ -126,10 +291,14 @@ func loadConfig() {
 	viper.AutomaticEnv()
 
 	// 从配置文件读取
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./config")
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigName("config")
+		viper.SetConfigType("yaml")
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("./config")
+	}
 
 	if err := viper.ReadInConfig(); err != nil {
 		// 配置文件不存在，使用默认值
@@ -17,7 +17,7 @@ import (
 func main() {
 	// 解析命令行参数
 	var (
-		id       = flag.String("id", "", "Cleaner ID")
+		id       = flag.String("id", os.Getenv("PROXY_ID"), "Cleaner ID (defaults to $PROXY_ID)")
 		httpPort = flag.String("http-port", "17100", "HTTP API server port")
 		help     = flag.Bool("help", false, "Show help information")
 	)
@@ -1,17 +1,30 @@
 package httpclient
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// schemaVersion 上报数据结构的版本
+const schemaVersion = "1"
+
 // Client HTTP客户端
 type Client struct {
-	httpPort string
+	httpPort  string
+	appToken  string // proxy启动时注入的上报凭证
+	reportURL string // proxy注入的上报地址，为空时使用 localhost:<httpPort>
+	client    *http.Client
+	sequence  uint64 // 上报序号，每次上报递增
 }
 
 // NewClient 创建新的HTTP客户端
@@ -19,29 +32,61 @@ func NewClient(httpPort string) *Client {
 	if httpPort == "" {
 		httpPort = "17100"
 	}
-	return &Client{
-		httpPort: httpPort,
+	c := &Client{
+		httpPort:  httpPort,
+		appToken:  os.Getenv("PROXY_APP_TOKEN"),
+		reportURL: os.Getenv("PROXY_REPORT_URL"),
+		client:    http.DefaultClient,
+	}
+
+	// proxy提供Unix socket时优先通过socket上报，无需知道proxy的端口
+	if socketPath := os.Getenv("PROXY_REPORT_SOCKET"); socketPath != "" {
+		c.reportURL = "http://unix/v1/app/status/report"
+		c.client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		}
 	}
+	return c
 }
 
-// ReportStatus 上报设备状态
+// ReportStatus 上报设备状态，status 中的设备信封（device_id、device_type、timestamp 等）原样上报
 func (c *Client) ReportStatus(ctx context.Context, status map[string]interface{}) error {
-	// 构建HTTP请求数据
+	// 构建HTTP请求数据：设备信封加上递增的序号和数据结构版本
 	requestData := map[string]interface{}{
-		"status": "running",
-		"data":   status["data"],
+		"status":         "running",
+		"schema_version": schemaVersion,
 	}
+	for k, v := range status {
+		requestData[k] = v
+	}
+	requestData["sequence"] = atomic.AddUint64(&c.sequence, 1)
 
 	requestJSON, err := json.Marshal(requestData)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	// 通过HTTP API发送状态报告
-	url := fmt.Sprintf("http://localhost:%s/app/status/report", c.httpPort)
+	// 通过HTTP API发送状态报告，proxy启用TLS时使用其注入的loopback上报地址
+	url := fmt.Sprintf("http://localhost:%s/v1/app/status/report", c.httpPort)
+	if c.reportURL != "" {
+		url = c.reportURL
+	}
 	
 	// 发送HTTP POST请求
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(requestJSON))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.appToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.appToken)
+	}
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send status report: %v", err)
 	}
@@ -55,6 +100,125 @@ func (c *Client) ReportStatus(ctx context.Context, status map[string]interface{}
 	return nil
 }
 
+// Command proxy下发的命令
+type Command struct {
+	ID     string                 `json:"id"`
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// CommandHandler 执行命令，返回的结果会回执给proxy
+type CommandHandler func(ctx context.Context, command Command) (map[string]interface{}, error)
+
+// ReceiveCommands 通过proxy的命令通道（SSE）接收并执行命令，连接断开后自动重连，直到ctx结束
+func (c *Client) ReceiveCommands(ctx context.Context, handler CommandHandler) {
+	for {
+		if err := c.receiveCommands(ctx, handler); err != nil && ctx.Err() == nil {
+			log.Printf("Command channel disconnected: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// receiveCommands 保持一个命令通道连接，逐个执行收到的命令
+func (c *Client) receiveCommands(ctx context.Context, handler CommandHandler) error {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/app/commands/stream", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("command stream failed with status: %d", resp.StatusCode)
+	}
+
+	// SSE事件以空行分隔，":" 开头的行是心跳注释
+	var event, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "command" && data != "" {
+				c.handleCommand(ctx, data, handler)
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(line, "data:")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("command stream closed")
+}
+
+// handleCommand 执行命令并回执结果
+func (c *Client) handleCommand(ctx context.Context, data string, handler CommandHandler) {
+	var command Command
+	if err := json.Unmarshal([]byte(data), &command); err != nil {
+		log.Printf("Invalid command: %v", err)
+		return
+	}
+
+	log.Printf("Received command %s (%s)", command.Name, command.ID)
+	result := map[string]interface{}{"success": true}
+	output, err := handler(ctx, command)
+	if err != nil {
+		result["success"] = false
+		result["error"] = err.Error()
+	} else {
+		result["result"] = output
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Failed to marshal command result: %v", err)
+		return
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/v1/app/commands/"+command.ID+"/result", body)
+	if err != nil {
+		log.Printf("Failed to send command result: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Command result for %s rejected with status: %d", command.ID, resp.StatusCode)
+	}
+}
+
+// do 向proxy的应用侧接口发送请求，携带上报凭证
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.appToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.appToken)
+	}
+	return c.client.Do(req)
+}
+
+// baseURL proxy应用侧接口的地址，与状态上报使用同一个监听器
+func (c *Client) baseURL() string {
+	if c.reportURL != "" {
+		// 兼容注入旧上报地址（不含 /v1）的proxy
+		base := strings.TrimSuffix(c.reportURL, "/app/status/report")
+		return strings.TrimSuffix(base, "/v1")
+	}
+	return fmt.Sprintf("http://localhost:%s", c.httpPort)
+}
+
+
 // Close 关闭连接（HTTP无需关闭）
 func (c *Client) Close() error {
 	return nil
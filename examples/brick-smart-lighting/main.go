@@ -16,7 +16,7 @@ import (
 
 func main() {
 	var (
-		id       = flag.String("id", "", "Lighting ID")
+		id       = flag.String("id", os.Getenv("PROXY_ID"), "Lighting ID (defaults to $PROXY_ID)")
 		httpPort = flag.String("http-port", "17100", "HTTP API server port")
 		help     = flag.Bool("help", false, "Show help information")
 	)
@@ -37,11 +37,16 @@ func main() {
 	light := lighting.NewLighting(*id, httpClient)
 
 	var wg sync.WaitGroup
-	wg.Add(1)
+	wg.Add(2)
 	go func() {
 		defer wg.Done()
 		light.Start(ctx)
 	}()
+	// 接收proxy下发的命令
+	go func() {
+		defer wg.Done()
+		httpClient.ReceiveCommands(ctx, light.HandleCommand)
+	}()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
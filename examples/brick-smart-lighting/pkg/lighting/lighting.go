@@ -2,14 +2,17 @@ package lighting
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"math/rand"
+	"sync"
 	"time"
 
 	"brick-smart-template/examples/brick-smart-lighting/pkg/httpclient"
 )
 
 type Lighting struct {
+	mu         sync.Mutex
 	ID         string
 	httpClient *httpclient.Client
 	isOn       bool
@@ -50,7 +53,43 @@ func (l *Lighting) Start(ctx context.Context) {
 	}
 }
 
+// HandleCommand 执行proxy下发的命令
+func (l *Lighting) HandleCommand(ctx context.Context, command httpclient.Command) (map[string]interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch command.Name {
+	case "set_power":
+		on, ok := command.Params["on"].(bool)
+		if !ok {
+			return nil, fmt.Errorf("on must be a boolean")
+		}
+		l.isOn = on
+		return map[string]interface{}{"is_on": l.isOn}, nil
+	case "set_brightness":
+		value, ok := command.Params["value"].(float64)
+		if !ok || value < 0 || value > 100 {
+			return nil, fmt.Errorf("value must be a number between 0 and 100")
+		}
+		l.brightness = int(value)
+		return map[string]interface{}{"brightness": l.brightness}, nil
+	case "set_scene":
+		scene, _ := command.Params["scene"].(string)
+		switch scene {
+		case "living_room", "bedroom", "kitchen", "bathroom", "study":
+		default:
+			return nil, fmt.Errorf("unsupported scene %q", scene)
+		}
+		l.scene = scene
+		return map[string]interface{}{"scene": l.scene}, nil
+	}
+	return nil, fmt.Errorf("unknown command %q", command.Name)
+}
+
 func (l *Lighting) simulate() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	// 模拟开关状态变化
 	if rand.Float64() < 0.1 {
 		l.isOn = !l.isOn
@@ -101,6 +140,7 @@ func (l *Lighting) simulate() {
 }
 
 func (l *Lighting) reportStatus(ctx context.Context) {
+	l.mu.Lock()
 	status := map[string]interface{}{
 		"device_id":   l.ID,
 		"device_type": "lighting",
@@ -119,6 +159,7 @@ func (l *Lighting) reportStatus(ctx context.Context) {
 	if l.errorCode != "" {
 		status["data"].(map[string]interface{})["error_code"] = l.errorCode
 	}
+	l.mu.Unlock()
 
 	if err := l.httpClient.ReportStatus(ctx, status); err != nil {
 		log.Printf("Failed to report status: %v", err)
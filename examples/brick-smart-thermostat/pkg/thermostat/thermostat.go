@@ -2,14 +2,17 @@ package thermostat
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"math/rand"
+	"sync"
 	"time"
 
 	"brick-smart-template/examples/brick-smart-thermostat/pkg/httpclient"
 )
 
 type Thermostat struct {
+	mu         sync.Mutex
 	ID         string
 	httpClient *httpclient.Client
 	mode       string
@@ -48,7 +51,36 @@ func (t *Thermostat) Start(ctx context.Context) {
 	}
 }
 
+// HandleCommand 执行proxy下发的命令
+func (t *Thermostat) HandleCommand(ctx context.Context, command httpclient.Command) (map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch command.Name {
+	case "set_target_temp":
+		value, ok := command.Params["value"].(float64)
+		if !ok || value < 5 || value > 35 {
+			return nil, fmt.Errorf("value must be a number between 5 and 35")
+		}
+		t.targetTemp = value
+		return map[string]interface{}{"target_temp": t.targetTemp}, nil
+	case "set_mode":
+		mode, _ := command.Params["mode"].(string)
+		switch mode {
+		case "auto", "eco", "comfort", "sleep":
+		default:
+			return nil, fmt.Errorf("unsupported mode %q", mode)
+		}
+		t.mode = mode
+		return map[string]interface{}{"mode": t.mode}, nil
+	}
+	return nil, fmt.Errorf("unknown command %q", command.Name)
+}
+
 func (t *Thermostat) simulate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	// 模拟温度变化
 	if t.roomTemp < t.targetTemp {
 		t.roomTemp += 0.2 + rand.Float64()*0.2
@@ -86,6 +118,7 @@ func (t *Thermostat) simulate() {
 }
 
 func (t *Thermostat) reportStatus(ctx context.Context) {
+	t.mu.Lock()
 	status := map[string]interface{}{
 		"device_id":   t.ID,
 		"device_type": "thermostat",
@@ -103,6 +136,7 @@ func (t *Thermostat) reportStatus(ctx context.Context) {
 	if t.errorCode != "" {
 		status["data"].(map[string]interface{})["error_code"] = t.errorCode
 	}
+	t.mu.Unlock()
 
 	if err := t.httpClient.ReportStatus(ctx, status); err != nil {
 		log.Printf("Failed to report status: %v", err)
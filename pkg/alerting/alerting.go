@@ -0,0 +1,310 @@
+// Package alerting 按配置的规则在每次状态上报时评估内部状态，产生告警
+package alerting
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	// maxResolved 保留的已解除告警数量
+	maxResolved = 100
+	// defaultInterval 定期检查持续时长条件（for）的默认间隔
+	defaultInterval = 15 * time.Second
+)
+
+// Rule 配置文件中 alerts.rules 的一条规则
+type Rule struct {
+	Name       string        `mapstructure:"name"`
+	DeviceType string        `mapstructure:"device_type"` // 为空时评估所有上报
+	Expr       string        `mapstructure:"expr"`
+	For        time.Duration `mapstructure:"for"`         // 条件需要持续的时长
+	ForReports int           `mapstructure:"for_reports"` // 条件需要连续满足的上报次数
+	Severity   string        `mapstructure:"severity"`
+	Summary    string        `mapstructure:"summary"`
+}
+
+// rule 编译后的规则
+type rule struct {
+	Rule
+	expr *Expr
+}
+
+// Engine 告警规则引擎
+type Engine struct {
+	mu       sync.Mutex
+	logger   *logrus.Logger
+	interval time.Duration // 定期检查持续时长条件的间隔
+	rules    []*rule
+	active   map[string]*models.Alert // 未解除的告警，按规则名索引
+	resolved []models.Alert           // 最近解除的告警，按解除时间先后排列
+}
+
+// Load 从viper配置创建告警引擎
+func Load(logger *logrus.Logger) (*Engine, error) {
+	rules, err := loadRules()
+	if err != nil {
+		return nil, err
+	}
+	interval := viper.GetDuration("alerts.evaluation_interval")
+	if interval < 0 {
+		return nil, fmt.Errorf("alerts.evaluation_interval must not be negative")
+	}
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	engine := &Engine{logger: logger, interval: interval, active: make(map[string]*models.Alert)}
+	if _, err := engine.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// loadRules 读取 alerts.rules
+func loadRules() ([]Rule, error) {
+	var rules []Rule
+	if err := viper.UnmarshalKey("alerts.rules", &rules); err != nil {
+		return nil, fmt.Errorf("invalid alerts config: %v", err)
+	}
+	return rules, nil
+}
+
+// reloadMu 串行化重新加载，SIGHUP 和管理接口可能同时触发，viper 的全局配置不能并发读取
+var reloadMu sync.Mutex
+
+// Reload 重新读取配置文件并替换规则，配置无效时保留原有规则
+// 返回规则数量和因规则被删除而解除的告警
+func (e *Engine) Reload() (int, []models.Alert, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return 0, nil, models.InvalidRequest(fmt.Sprintf("failed to read config: %v", err))
+		}
+	}
+	rules, err := loadRules()
+	if err != nil {
+		return 0, nil, models.InvalidRequest(err.Error())
+	}
+	resolved, err := e.SetRules(rules)
+	if err != nil {
+		return 0, nil, models.InvalidRequest(err.Error())
+	}
+	return len(rules), resolved, nil
+}
+
+// SetRules 校验并替换规则。规则名不变的告警保留状态，已删除规则的告警被解除
+// 返回被解除的告警，待定的告警直接丢弃
+func (e *Engine) SetRules(rules []Rule) ([]models.Alert, error) {
+	compiled := make([]*rule, 0, len(rules))
+	names := make(map[string]bool)
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("alert rule %d: name is required", i)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("alert rule %s: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+		if r.For < 0 || r.ForReports < 0 {
+			return nil, fmt.Errorf("alert rule %s: for and for_reports must not be negative", r.Name)
+		}
+		expr, err := Compile(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %s: invalid expr: %v", r.Name, err)
+		}
+		compiled = append(compiled, &rule{Rule: r, expr: expr})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = compiled
+	now := time.Now()
+	var resolved []models.Alert
+	for name, alert := range e.active {
+		if !names[name] {
+			delete(e.active, name)
+			if alert.State == models.AlertStateFiring {
+				resolved = append(resolved, e.resolve(alert, now))
+			}
+		}
+	}
+	e.logger.Infof("Loaded %d alert rules", len(compiled))
+	return resolved, nil
+}
+
+// Evaluate 用一次上报的内部状态评估所有规则，返回状态发生变化的告警
+func (e *Engine) Evaluate(now time.Time, deviceType string, data map[string]interface{}) []models.Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changed []models.Alert
+	for _, r := range e.rules {
+		if r.DeviceType != "" && r.DeviceType != deviceType {
+			continue
+		}
+		alert := e.active[r.Name]
+		if !r.expr.Match(data) {
+			if alert == nil {
+				continue
+			}
+			delete(e.active, r.Name)
+			// 未到达触发条件的告警直接丢弃
+			if alert.State == models.AlertStateFiring {
+				changed = append(changed, e.resolve(alert, now))
+			}
+			continue
+		}
+
+		created := alert == nil
+		if created {
+			alert = &models.Alert{
+				ID:       newAlertID(),
+				Rule:     r.Name,
+				State:    models.AlertStatePending,
+				ActiveAt: now,
+			}
+			e.active[r.Name] = alert
+		}
+		alert.Expr = r.Expr
+		alert.Severity = r.Severity
+		alert.Summary = r.Summary
+		alert.Reports++
+		alert.Values = r.expr.Values(data)
+
+		if alert.State == models.AlertStatePending && alert.Reports >= r.ForReports && now.Sub(alert.ActiveAt) >= r.For {
+			changed = append(changed, e.fire(alert, r, now))
+		} else if created {
+			changed = append(changed, copyAlert(alert))
+		}
+	}
+	return changed
+}
+
+// Interval 定期调用 Tick 的间隔
+func (e *Engine) Interval() time.Duration {
+	return e.interval
+}
+
+// Tick 检查持续时长条件（for）：条件满足的时长已到达的 pending 告警变为 firing，无需等待下一次上报。
+// 返回状态发生变化的告警
+func (e *Engine) Tick(now time.Time) []models.Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changed []models.Alert
+	for _, r := range e.rules {
+		alert := e.active[r.Name]
+		if alert == nil || alert.State != models.AlertStatePending {
+			continue
+		}
+		if alert.Reports >= r.ForReports && now.Sub(alert.ActiveAt) >= r.For {
+			changed = append(changed, e.fire(alert, r, now))
+		}
+	}
+	return changed
+}
+
+// ResolveAll 内部状态被清空（如应用停止）时解除所有 firing 的告警并丢弃 pending 的告警，返回被解除的告警
+func (e *Engine) ResolveAll(now time.Time) []models.Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changed []models.Alert
+	for name, alert := range e.active {
+		delete(e.active, name)
+		if alert.State == models.AlertStateFiring {
+			changed = append(changed, e.resolve(alert, now))
+		}
+	}
+	return changed
+}
+
+// fire 告警变为 firing（调用方需持有锁）
+func (e *Engine) fire(alert *models.Alert, r *rule, now time.Time) models.Alert {
+	alert.State = models.AlertStateFiring
+	firedAt := now
+	alert.FiredAt = &firedAt
+	e.logger.Warnf("Alert %s firing: %s", r.Name, r.Expr)
+	return copyAlert(alert)
+}
+
+// resolve 解除告警并加入已解除列表（调用方需持有锁）
+func (e *Engine) resolve(alert *models.Alert, now time.Time) models.Alert {
+	alert.State = models.AlertStateResolved
+	resolvedAt := now
+	alert.ResolvedAt = &resolvedAt
+	e.logger.Infof("Alert %s resolved", alert.Rule)
+
+	resolved := copyAlert(alert)
+	e.resolved = append(e.resolved, resolved)
+	if len(e.resolved) > maxResolved {
+		e.resolved = e.resolved[len(e.resolved)-maxResolved:]
+	}
+	return resolved
+}
+
+// Alerts 列出告警：告警中的在前，其次为待定的，最后为最近解除的；state 不为空时只返回该状态
+func (e *Engine) Alerts(state string) ([]models.Alert, error) {
+	switch state {
+	case "", models.AlertStatePending, models.AlertStateFiring, models.AlertStateResolved:
+	default:
+		return nil, models.InvalidRequest(fmt.Sprintf("invalid state %q", state))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := []models.Alert{}
+	for _, alert := range e.active {
+		if state == "" || alert.State == state {
+			alerts = append(alerts, copyAlert(alert))
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].State != alerts[j].State {
+			return alerts[i].State == models.AlertStateFiring
+		}
+		return alerts[i].ActiveAt.Before(alerts[j].ActiveAt)
+	})
+	if state == "" || state == models.AlertStateResolved {
+		for i := len(e.resolved) - 1; i >= 0; i-- {
+			alerts = append(alerts, e.resolved[i])
+		}
+	}
+	return alerts, nil
+}
+
+// copyAlert 复制告警，避免调用方读取时被并发修改
+func copyAlert(alert *models.Alert) models.Alert {
+	copied := *alert
+	if alert.Values != nil {
+		copied.Values = make(map[string]interface{}, len(alert.Values))
+		for k, v := range alert.Values {
+			copied.Values[k] = v
+		}
+	}
+	return copied
+}
+
+// newAlertID 生成随机告警id
+func newAlertID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,222 @@
+package alerting
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// newTestEngine 创建使用给定规则的告警引擎
+func newTestEngine(t *testing.T, rules ...Rule) *Engine {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	engine := &Engine{logger: logger, interval: defaultInterval, active: make(map[string]*models.Alert)}
+	if _, err := engine.SetRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+// states 返回告警的状态
+func states(alerts []models.Alert) []string {
+	result := []string{}
+	for _, alert := range alerts {
+		result = append(result, alert.State)
+	}
+	return result
+}
+
+func TestAlertStateMachine(t *testing.T) {
+	low := map[string]interface{}{"battery_level": 10.0}
+	ok := map[string]interface{}{"battery_level": 80.0}
+
+	type step struct {
+		offset time.Duration // 相对开始时间
+		data   map[string]interface{}
+		tick   bool // 为true时调用 Tick 而不是 Evaluate
+		want   []string
+	}
+	tests := []struct {
+		name  string
+		rule  Rule
+		steps []step
+	}{
+		{
+			"fires immediately",
+			Rule{Name: "low", Expr: "battery_level < 20"},
+			[]step{
+				{0, low, false, []string{models.AlertStateFiring}},
+				{time.Second, low, false, []string{}},
+				{2 * time.Second, ok, false, []string{models.AlertStateResolved}},
+				{3 * time.Second, ok, false, []string{}},
+			},
+		},
+		{
+			"for_reports",
+			Rule{Name: "low", Expr: "battery_level < 20", ForReports: 3},
+			[]step{
+				{0, low, false, []string{models.AlertStatePending}},
+				{time.Second, low, false, []string{}},
+				{2 * time.Second, low, false, []string{models.AlertStateFiring}},
+			},
+		},
+		{
+			"pending is dropped without resolving",
+			Rule{Name: "low", Expr: "battery_level < 20", ForReports: 3},
+			[]step{
+				{0, low, false, []string{models.AlertStatePending}},
+				{time.Second, ok, false, []string{}},
+				{2 * time.Second, low, false, []string{models.AlertStatePending}},
+			},
+		},
+		{
+			"for fires on tick without new report",
+			Rule{Name: "low", Expr: "battery_level < 20", For: 10 * time.Minute},
+			[]step{
+				{0, low, false, []string{models.AlertStatePending}},
+				{5 * time.Minute, nil, true, []string{}},
+				{10 * time.Minute, nil, true, []string{models.AlertStateFiring}},
+				{11 * time.Minute, nil, true, []string{}},
+				{12 * time.Minute, ok, false, []string{models.AlertStateResolved}},
+			},
+		},
+		{
+			"for fires on report",
+			Rule{Name: "low", Expr: "battery_level < 20", For: time.Minute},
+			[]step{
+				{0, low, false, []string{models.AlertStatePending}},
+				{time.Minute, low, false, []string{models.AlertStateFiring}},
+			},
+		},
+		{
+			"tick waits for for_reports",
+			Rule{Name: "low", Expr: "battery_level < 20", For: time.Minute, ForReports: 2},
+			[]step{
+				{0, low, false, []string{models.AlertStatePending}},
+				{2 * time.Minute, nil, true, []string{}},
+				{3 * time.Minute, low, false, []string{models.AlertStateFiring}},
+			},
+		},
+		{
+			"device type filter",
+			Rule{Name: "low", DeviceType: "cleaner", Expr: "battery_level < 20"},
+			[]step{
+				{0, low, false, []string{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestEngine(t, tt.rule)
+			start := time.Now()
+			for i, s := range tt.steps {
+				var changed []models.Alert
+				if s.tick {
+					changed = engine.Tick(start.Add(s.offset))
+				} else {
+					changed = engine.Evaluate(start.Add(s.offset), "thermostat", s.data)
+				}
+				if got := states(changed); !reflect.DeepEqual(got, s.want) {
+					t.Fatalf("step %d: got %v, want %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveAll(t *testing.T) {
+	engine := newTestEngine(t,
+		Rule{Name: "low", Expr: "battery_level < 20"},
+		Rule{Name: "slow", Expr: "battery_level < 20", For: time.Hour},
+	)
+	now := time.Now()
+	engine.Evaluate(now, "", map[string]interface{}{"battery_level": 10.0})
+
+	changed := engine.ResolveAll(now.Add(time.Second))
+	if len(changed) != 1 || changed[0].Rule != "low" || changed[0].State != models.AlertStateResolved {
+		t.Fatalf("ResolveAll() = %+v, want only the firing alert resolved", changed)
+	}
+	if alerts, _ := engine.Alerts(models.AlertStatePending); len(alerts) != 0 {
+		t.Errorf("pending alerts were not dropped: %+v", alerts)
+	}
+	if alerts, _ := engine.Alerts(models.AlertStateResolved); len(alerts) != 1 || alerts[0].ResolvedAt == nil {
+		t.Errorf("resolved alerts = %+v", alerts)
+	}
+	if changed := engine.Tick(now.Add(2 * time.Hour)); len(changed) != 0 {
+		t.Errorf("Tick() after ResolveAll = %+v", changed)
+	}
+}
+
+func TestSetRules(t *testing.T) {
+	engine := newTestEngine(t, Rule{Name: "low", Expr: "battery_level < 20"})
+	now := time.Now()
+	engine.Evaluate(now, "", map[string]interface{}{"battery_level": 10.0})
+
+	invalid := [][]Rule{
+		{{Expr: "x > 1"}},
+		{{Name: "a", Expr: "x > 1"}, {Name: "a", Expr: "x > 2"}},
+		{{Name: "a", Expr: "x >"}},
+		{{Name: "a", Expr: "x > 1", For: -time.Second}},
+	}
+	for _, rules := range invalid {
+		if _, err := engine.SetRules(rules); err == nil {
+			t.Errorf("SetRules(%+v): expected error", rules)
+		}
+	}
+	if alerts, _ := engine.Alerts(models.AlertStateFiring); len(alerts) != 1 {
+		t.Fatalf("invalid rules changed alert state: %+v", alerts)
+	}
+
+	// 删除规则时解除其告警，并返回给调用方发布
+	resolved, err := engine.SetRules([]Rule{{Name: "other", Expr: "x > 1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved) != 1 || resolved[0].Rule != "low" || resolved[0].State != models.AlertStateResolved {
+		t.Errorf("SetRules() resolved = %+v", resolved)
+	}
+	if alerts, _ := engine.Alerts(""); len(alerts) != 1 || alerts[0].State != models.AlertStateResolved {
+		t.Errorf("alerts after removing rule = %+v", alerts)
+	}
+}
+
+func TestReloadConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "alerts:\n  rules:\n    - name: low\n      expr: battery_level < 20\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(path)
+	t.Cleanup(viper.Reset)
+
+	// SIGHUP 和管理接口可能同时触发重新加载
+	engine := newTestEngine(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if count, _, err := engine.Reload(); err != nil || count != 1 {
+				t.Errorf("Reload() = %d, %v, want 1", count, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAlertsFilter(t *testing.T) {
+	engine := newTestEngine(t)
+	if _, err := engine.Alerts("unknown"); err == nil {
+		t.Errorf("expected error for invalid state")
+	}
+}
@@ -0,0 +1,403 @@
+package alerting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr 编译后的告警表达式
+//
+// 语法：
+//
+//	expr       = or
+//	or         = and { ("||" | "or") and }
+//	and        = unary { ("&&" | "and") unary }
+//	unary      = ("!" | "not") unary | "(" expr ")" | func | comparison
+//	func       = ("present" | "absent") "(" field ")"
+//	comparison = operand [ ("<" | "<=" | ">" | ">=" | "==" | "!=") operand ]
+//	operand    = number | string | "true" | "false" | field
+//
+// 字段为内部状态中的字段名，嵌套字段以 "." 连接。字段不存在或类型不匹配时比较结果为false；
+// 单独的字段只有值为布尔值 true 时为真。
+type Expr struct {
+	source string
+	root   node
+	fields []string // 表达式引用的字段，按出现顺序
+}
+
+// node 表达式语法树的节点，求值结果为 float64、string、bool 或 nil（字段不存在）
+type node interface {
+	eval(data map[string]interface{}) interface{}
+}
+
+type literal struct{ value interface{} }
+
+type fieldRef struct{ path []string }
+
+type comparison struct {
+	op          string
+	left, right node
+}
+
+type logical struct {
+	and         bool
+	left, right node
+}
+
+type negation struct{ operand node }
+
+type presence struct {
+	field fieldRef
+	want  bool
+}
+
+// Compile 编译告警表达式
+func Compile(source string) (*Expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, fields: make(map[string]bool)}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Expr{source: source, root: root, fields: p.order}, nil
+}
+
+// String 表达式原文
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Fields 表达式引用的字段
+func (e *Expr) Fields() []string {
+	return e.fields
+}
+
+// Values 表达式引用的字段在内部状态中的值，不存在的字段为nil
+func (e *Expr) Values(data map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(e.fields))
+	for _, field := range e.fields {
+		values[field] = fieldRef{path: strings.Split(field, ".")}.eval(data)
+	}
+	return values
+}
+
+// Match 对内部状态求值，结果为布尔值 true 时匹配
+func (e *Expr) Match(data map[string]interface{}) bool {
+	return e.root.eval(data) == true
+}
+
+func (n literal) eval(map[string]interface{}) interface{} {
+	return n.value
+}
+
+func (n fieldRef) eval(data map[string]interface{}) interface{} {
+	var value interface{} = data
+	for _, key := range n.path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if value, ok = object[key]; !ok {
+			return nil
+		}
+	}
+	return value
+}
+
+func (n comparison) eval(data map[string]interface{}) interface{} {
+	left, right := n.left.eval(data), n.right.eval(data)
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false
+		}
+		return compareOrdered(n.op, l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		return compareOrdered(n.op, l, r)
+	case bool:
+		r, ok := right.(bool)
+		if !ok {
+			return false
+		}
+		switch n.op {
+		case "==":
+			return l == r
+		case "!=":
+			return l != r
+		}
+	}
+	return false
+}
+
+// compareOrdered 比较数值或字符串
+func compareOrdered[T float64 | string](op string, l, r T) bool {
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	}
+	return false
+}
+
+func (n logical) eval(data map[string]interface{}) interface{} {
+	left := n.left.eval(data) == true
+	if n.and {
+		return left && n.right.eval(data) == true
+	}
+	return left || n.right.eval(data) == true
+}
+
+func (n negation) eval(data map[string]interface{}) interface{} {
+	return n.operand.eval(data) != true
+}
+
+func (n presence) eval(data map[string]interface{}) interface{} {
+	return (n.field.eval(data) != nil) == n.want
+}
+
+// token 词法单元
+type token struct {
+	kind string // number、string、ident、op
+	text string
+}
+
+// tokenize 把表达式切分为词法单元
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			j := i + 1
+			var text strings.Builder
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				text.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, token{kind: "string", text: text.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && expectsOperand(tokens)):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E' ||
+				// 指数的符号，如 1e-5
+				((runes[j] == '-' || runes[j] == '+') && (runes[j-1] == 'e' || runes[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: "number", text: string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: "ident", text: string(runes[i:j])})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"<=", ">=", "==", "!=", "&&", "||", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", r, i)
+			}
+			tokens = append(tokens, token{kind: "op", text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// expectsOperand 下一个词法单元是否应为操作数（用于区分负数和减号）
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == "op" && last.text != ")"
+}
+
+// parser 递归下降解析器
+type parser struct {
+	tokens []token
+	pos    int
+	fields map[string]bool
+	order  []string
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// accept 下一个词法单元为指定的运算符或关键字时消费它
+func (p *parser) accept(texts ...string) (string, bool) {
+	t := p.peek()
+	if t == nil || t.kind == "string" || t.kind == "number" {
+		return "", false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{and: true, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand: operand}, nil
+	}
+	if _, ok := p.accept("("); ok {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing )")
+		}
+		return inner, nil
+	}
+	if name, ok := p.accept("present", "absent"); ok {
+		if _, ok := p.accept("("); !ok {
+			return nil, fmt.Errorf("%s requires a field argument", name)
+		}
+		t := p.peek()
+		if t == nil || t.kind != "ident" {
+			return nil, fmt.Errorf("%s requires a field argument", name)
+		}
+		p.pos++
+		field := p.field(t.text)
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing ) after %s(%s", name, t.text)
+		}
+		return presence{field: field, want: name == "present"}, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("<=", ">=", "==", "!=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	switch t.kind {
+	case "number":
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		p.pos++
+		return literal{value: value}, nil
+	case "string":
+		p.pos++
+		return literal{value: t.text}, nil
+	case "ident":
+		p.pos++
+		switch t.text {
+		case "true":
+			return literal{value: true}, nil
+		case "false":
+			return literal{value: false}, nil
+		case "and", "or", "not", "present", "absent":
+			return nil, fmt.Errorf("unexpected %q", t.text)
+		}
+		return p.field(t.text), nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// field 记录引用的字段
+func (p *parser) field(name string) fieldRef {
+	if !p.fields[name] {
+		p.fields[name] = true
+		p.order = append(p.order, name)
+	}
+	return fieldRef{path: strings.Split(name, ".")}
+}
@@ -0,0 +1,142 @@
+package alerting
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExprMatch(t *testing.T) {
+	data := map[string]interface{}{
+		"battery_level": 15.0,
+		"drift":         0.00002,
+		"offset":        -3.0,
+		"mode":          "eco",
+		"charging":      true,
+		"docked":        false,
+		"sensor":        map[string]interface{}{"temp": 21.5, "ok": true},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"battery_level < 20", true},
+		{"battery_level <= 15", true},
+		{"battery_level > 15", false},
+		{"battery_level >= 15", true},
+		{"battery_level == 15", true},
+		{"battery_level != 15", false},
+		{"20 > battery_level", true},
+		{"drift > 1e-5", true},
+		{"drift < 1E-4", true},
+		{"drift < 2e+0", true},
+		{"offset == -3", true},
+		{"offset < -2.5", true},
+		{"battery_level > -1e3", true},
+		{"mode == 'eco'", true},
+		{`mode == "eco"`, true},
+		{"mode != 'eco'", false},
+		{"mode < 'f'", true},
+		{"'it\\'s' == 'it\\'s'", true},
+		{"charging", true},
+		{"docked", false},
+		{"charging == true", true},
+		{"docked != true", true},
+		{"sensor.temp > 20", true},
+		{"sensor.ok", true},
+		{"sensor.missing > 0", false},
+		{"mode > 1", false},
+		{"battery_level == 'low'", false},
+		{"missing", false},
+		{"battery_level", false},
+		{"present(mode)", true},
+		{"present(error_code)", false},
+		{"absent(error_code)", true},
+		{"present(sensor.temp)", true},
+		{"not charging", false},
+		{"!docked", true},
+		{"battery_level < 20 and mode == 'eco'", true},
+		{"battery_level < 20 && mode == 'off'", false},
+		{"battery_level > 20 or charging", true},
+		{"battery_level > 20 || docked", false},
+		{"docked or charging and mode == 'eco'", true},
+		{"(docked or charging) and mode == 'off'", false},
+		{"not (battery_level < 20 and charging)", false},
+		{"not missing > 0", true},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := expr.Match(data); got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"battery_level <",
+		"< 20",
+		"battery_level < 20 and",
+		"(battery_level < 20",
+		"battery_level < 20)",
+		"present battery_level",
+		"present(20)",
+		"present(mode",
+		"mode == 'eco",
+		"battery_level # 20",
+		"1e",
+		"a-1",
+		"1.2.3 > 0",
+		"a < b < c",
+		"and",
+	}
+	for _, source := range tests {
+		if _, err := Compile(source); err == nil {
+			t.Errorf("Compile(%q): expected error", source)
+		}
+	}
+}
+
+func TestTokenizeNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"1e-5", []string{"1e-5"}},
+		{"-1.5E+3", []string{"-1.5E+3"}},
+		{"x > -2", []string{"x", ">", "-2"}},
+		{"(2)", []string{"(", "2", ")"}},
+	}
+	for _, tt := range tests {
+		tokens, err := tokenize(tt.source)
+		if err != nil {
+			t.Errorf("tokenize(%q): %v", tt.source, err)
+			continue
+		}
+		var got []string
+		for _, token := range tokens {
+			got = append(got, token.text)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestExprFieldsAndValues(t *testing.T) {
+	expr, err := Compile("sensor.temp > 30 or present(error_code) or sensor.temp < 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expr.Fields(), []string{"sensor.temp", "error_code"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields() = %v, want %v", got, want)
+	}
+	values := expr.Values(map[string]interface{}{"sensor": map[string]interface{}{"temp": 35.0}})
+	if want := map[string]interface{}{"sensor.temp": 35.0, "error_code": nil}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Values() = %v, want %v", values, want)
+	}
+}
@@ -0,0 +1,89 @@
+package appmanager
+
+import (
+	"net/http"
+	"time"
+
+	"brick-smart-template/pkg/alerting"
+	"brick-smart-template/pkg/models"
+)
+
+// errAlertingDisabled 未设置告警引擎
+var errAlertingDisabled = models.NewAPIError(http.StatusNotFound, models.ErrorCodeNotFound, "alerting is not enabled")
+
+// SetAlerting 设置在每次状态上报时评估的告警引擎，并定期检查持续时长条件
+func (m *Manager) SetAlerting(engine *alerting.Engine) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.alerts = engine
+	go m.tickAlerts(engine)
+}
+
+// tickAlerts 定期检查告警的持续时长条件，告警引擎被替换后退出
+func (m *Manager) tickAlerts(engine *alerting.Engine) {
+	ticker := time.NewTicker(engine.Interval())
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		m.mu.RLock()
+		current := m.alerts
+		m.mu.RUnlock()
+		if current != engine {
+			return
+		}
+		m.publishAlerts(engine.Tick(now))
+	}
+}
+
+// evaluateAlerts 用内部状态评估告警规则，发布状态变化的告警（调用方需持有锁）
+func (m *Manager) evaluateAlerts(t time.Time, deviceType string, data map[string]interface{}) {
+	if m.alerts == nil {
+		return
+	}
+	m.publishAlerts(m.alerts.Evaluate(t, deviceType, data))
+}
+
+// resolveAlerts 内部状态被清空时解除所有告警（调用方需持有锁）
+func (m *Manager) resolveAlerts(t time.Time) {
+	if m.alerts == nil {
+		return
+	}
+	m.publishAlerts(m.alerts.ResolveAll(t))
+}
+
+// publishAlerts 发布状态变化的告警
+func (m *Manager) publishAlerts(alerts []models.Alert) {
+	for _, alert := range alerts {
+		m.events.publish(models.StreamEventAlert, alert)
+	}
+}
+
+// Alerts 列出告警，state 不为空时只返回该状态的告警
+func (m *Manager) Alerts(state string) ([]models.Alert, error) {
+	m.mu.RLock()
+	engine := m.alerts
+	m.mu.RUnlock()
+
+	if engine == nil {
+		return nil, errAlertingDisabled
+	}
+	return engine.Alerts(state)
+}
+
+// ReloadAlertRules 重新读取配置文件中的告警规则，发布因规则被删除而解除的告警，返回规则数量
+func (m *Manager) ReloadAlertRules() (int, error) {
+	m.mu.RLock()
+	engine := m.alerts
+	m.mu.RUnlock()
+
+	if engine == nil {
+		return 0, errAlertingDisabled
+	}
+	count, resolved, err := engine.Reload()
+	if err != nil {
+		return 0, err
+	}
+	m.publishAlerts(resolved)
+	return count, nil
+}
@@ -0,0 +1,222 @@
+package appmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/models"
+)
+
+const (
+	// maxCommands 保留的命令数量（包括已结束的命令）
+	maxCommands = 100
+	// defaultCommandTimeout 请求未指定截止时间时的默认超时
+	defaultCommandTimeout = time.Minute
+)
+
+// commandQueue 下发给应用的命令队列
+type commandQueue struct {
+	mu       sync.Mutex
+	commands map[string]*models.Command
+	order    []string      // 按创建顺序排列的命令id，用于淘汰旧命令
+	pending  []string      // 等待应用接收的命令id
+	notify   chan struct{} // 有新命令时关闭并替换，唤醒等待的接收方
+}
+
+// newCommandQueue 创建命令队列
+func newCommandQueue() *commandQueue {
+	return &commandQueue{
+		commands: make(map[string]*models.Command),
+		notify:   make(chan struct{}),
+	}
+}
+
+// EnqueueCommand 将命令加入队列，等待应用通过命令通道接收
+func (m *Manager) EnqueueCommand(actor string, request models.CommandRequest) (*models.Command, error) {
+	m.mu.RLock()
+	configured := m.appInfo != nil
+	m.mu.RUnlock()
+	if !configured {
+		return nil, models.ErrNotConfigured
+	}
+	if request.Name == "" {
+		return nil, models.InvalidRequest("command name is required")
+	}
+
+	now := time.Now()
+	deadline := now.Add(defaultCommandTimeout)
+	if request.Deadline != nil {
+		if !request.Deadline.After(now) {
+			return nil, models.InvalidRequest(fmt.Sprintf("command deadline %s is in the past", request.Deadline.Format(time.RFC3339)))
+		}
+		deadline = *request.Deadline
+	}
+
+	id, err := newCommandID()
+	if err != nil {
+		return nil, err
+	}
+	command := &models.Command{
+		ID:        id,
+		Name:      request.Name,
+		Params:    request.Params,
+		Deadline:  deadline,
+		Status:    models.CommandStatusPending,
+		Actor:     actor,
+		CreatedAt: now,
+	}
+
+	q := m.commands
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.commands[id] = command
+	q.order = append(q.order, id)
+	q.pending = append(q.pending, id)
+	q.evict()
+	close(q.notify)
+	q.notify = make(chan struct{})
+
+	m.logger.Infof("Queued command %s (%s) from %s", command.Name, id, actor)
+	result := *command
+	return &result, nil
+}
+
+// NextCommand 等待下一个待接收的命令并从待接收队列中取出，ctx结束时返回ctx的错误
+// 调用方写出命令后调用 MarkCommandDelivered，写出失败时调用 ReleaseCommand 放回队列
+func (m *Manager) NextCommand(ctx context.Context) (*models.Command, error) {
+	q := m.commands
+	for {
+		// 接收方已断开时不再取出命令
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		q.mu.Lock()
+		now := time.Now()
+		for len(q.pending) > 0 {
+			command, ok := q.commands[q.pending[0]]
+			q.pending = q.pending[1:]
+			if !ok || q.expire(command, now) {
+				continue
+			}
+			result := *command
+			q.mu.Unlock()
+			return &result, nil
+		}
+		notify := q.notify
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// MarkCommandDelivered 命令已成功写出给应用，标记为已下发
+func (m *Manager) MarkCommandDelivered(id string, at time.Time) {
+	q := m.commands
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	command, ok := q.commands[id]
+	if !ok || command.Status != models.CommandStatusPending {
+		return
+	}
+	command.Status = models.CommandStatusDelivered
+	command.DeliveredAt = &at
+}
+
+// ReleaseCommand 命令未能写出给应用，放回待接收队列的最前面等待重新下发
+func (m *Manager) ReleaseCommand(id string) {
+	q := m.commands
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	command, ok := q.commands[id]
+	if !ok || command.Status != models.CommandStatusPending {
+		return
+	}
+	q.pending = append([]string{id}, q.pending...)
+	close(q.notify)
+	q.notify = make(chan struct{})
+	m.logger.Warnf("Command %s (%s) was not delivered, requeued", command.Name, id)
+}
+
+// CompleteCommand 记录应用对命令的执行结果
+func (m *Manager) CompleteCommand(id string, result models.CommandResultRequest) (*models.Command, error) {
+	q := m.commands
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	command, ok := q.commands[id]
+	if !ok {
+		return nil, ErrCommandNotFound
+	}
+	now := time.Now()
+	if q.expire(command, now) || command.Done() {
+		return nil, ErrCommandDone
+	}
+
+	command.Status = models.CommandStatusSucceeded
+	if !result.Success {
+		command.Status = models.CommandStatusFailed
+	}
+	command.Result = result.Result
+	command.Error = result.Error
+	command.CompletedAt = &now
+
+	m.logger.Infof("Command %s (%s) %s", command.Name, id, command.Status)
+	completed := *command
+	return &completed, nil
+}
+
+// GetCommand 获取命令状态
+func (m *Manager) GetCommand(id string) (*models.Command, error) {
+	q := m.commands
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	command, ok := q.commands[id]
+	if !ok {
+		return nil, ErrCommandNotFound
+	}
+	q.expire(command, time.Now())
+	result := *command
+	return &result, nil
+}
+
+// expire 未结束的命令超过截止时间时标记为过期（调用方需持有锁）
+func (q *commandQueue) expire(command *models.Command, now time.Time) bool {
+	if command.Status == models.CommandStatusExpired {
+		return true
+	}
+	if command.Done() || now.Before(command.Deadline) {
+		return false
+	}
+	command.Status = models.CommandStatusExpired
+	command.CompletedAt = &now
+	return true
+}
+
+// evict 淘汰超出数量上限的最早的命令（调用方需持有锁）
+func (q *commandQueue) evict() {
+	for len(q.order) > maxCommands {
+		delete(q.commands, q.order[0])
+		q.order = q.order[1:]
+	}
+}
+
+// newCommandID 生成随机命令id
+func newCommandID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate command id: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
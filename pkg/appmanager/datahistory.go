@@ -0,0 +1,36 @@
+package appmanager
+
+import (
+	"net/http"
+	"time"
+
+	"brick-smart-template/pkg/models"
+	"brick-smart-template/pkg/timeseries"
+)
+
+// SetDataHistory 设置记录内部状态字段历史的时序存储
+func (m *Manager) SetDataHistory(store *timeseries.Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dataHistory = store
+}
+
+// recordDataHistory 记录一次内部状态（调用方需持有锁）
+func (m *Manager) recordDataHistory(t time.Time, data map[string]interface{}) {
+	if m.dataHistory != nil {
+		m.dataHistory.Record(t, data)
+	}
+}
+
+// DataHistory 查询内部状态中一个字段的历史
+func (m *Manager) DataHistory(query timeseries.Query) (*models.DataHistory, error) {
+	m.mu.RLock()
+	store := m.dataHistory
+	m.mu.RUnlock()
+
+	if store == nil {
+		return nil, models.NewAPIError(http.StatusNotFound, models.ErrorCodeNotFound, "data history is not enabled")
+	}
+	return store.Query(query)
+}
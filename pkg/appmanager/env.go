@@ -0,0 +1,179 @@
+package appmanager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"brick-smart-template/pkg/models"
+)
+
+// redactedValue 敏感值在状态和日志中的占位符
+const redactedValue = "******"
+
+// templateData 启动参数和环境变量模板的渲染上下文
+// 例如 "{{.ProxyID}}"、"{{.AppName}}"、"{{.Profile.room}}"
+type templateData struct {
+	ProxyID string
+	AppName string
+	Profile map[string]interface{}
+}
+
+// secretValue 需要在状态和日志中脱敏的值，name 为空表示不对应环境变量（如应用凭证）
+type secretValue struct {
+	name  string
+	value string
+}
+
+// launchEnv 启动子进程时使用的环境变量
+type launchEnv struct {
+	env     []string          // 传给子进程的完整环境变量
+	display map[string]string // 对外展示的注入变量（已脱敏）
+	secrets []secretValue     // 需要在日志中脱敏的值
+}
+
+// parseTemplate 解析模板字符串，缺失的字段视为错误
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("value").Option("missingkey=error").Parse(text)
+}
+
+// renderTemplate 渲染模板字符串，不含模板语法时原样返回
+func renderTemplate(text string, data templateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// validateEnvPolicy 配置时校验模板语法和文件来源
+func validateEnvPolicy(appInfo *models.AppInfo) error {
+	for _, arg := range appInfo.Args {
+		if _, err := parseTemplate(arg); err != nil {
+			return fmt.Errorf("invalid template in args %q: %v", arg, err)
+		}
+	}
+	for k, v := range appInfo.Env {
+		if k == "" || strings.Contains(k, "=") {
+			return fmt.Errorf("invalid env name %q", k)
+		}
+		if _, err := parseTemplate(v); err != nil {
+			return fmt.Errorf("invalid template in env %s: %v", k, err)
+		}
+	}
+	for k, path := range appInfo.EnvFiles {
+		if k == "" || strings.Contains(k, "=") {
+			return fmt.Errorf("invalid env name %q", k)
+		}
+		if path == "" {
+			return fmt.Errorf("env file for %s is empty", k)
+		}
+	}
+	return nil
+}
+
+// envAllowed 判断变量名是否在白名单中，支持 PREFIX_* 前缀匹配
+func envAllowed(name string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritedEnv 返回子进程继承的proxy环境变量
+func inheritedEnv(appInfo *models.AppInfo) []string {
+	if !appInfo.CleanEnv {
+		return os.Environ()
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		if envAllowed(name, appInfo.EnvAllowlist) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// buildLaunchEnv 根据环境变量策略构建子进程环境
+func buildLaunchEnv(appInfo *models.AppInfo, data templateData) (*launchEnv, error) {
+	secretNames := make(map[string]bool)
+	for _, name := range appInfo.SecretEnv {
+		secretNames[name] = true
+	}
+
+	result := &launchEnv{
+		env:     inheritedEnv(appInfo),
+		display: make(map[string]string),
+	}
+	add := func(name, value string, secret bool) {
+		result.env = append(result.env, fmt.Sprintf("%s=%s", name, value))
+		if secret {
+			result.display[name] = redactedValue
+			if value != "" {
+				result.secrets = append(result.secrets, secretValue{name: name, value: value})
+			}
+		} else {
+			result.display[name] = value
+		}
+	}
+
+	for k, v := range appInfo.Env {
+		value, err := renderTemplate(v, data)
+		if err != nil {
+			return nil, invalidProfile("failed to render env %s: %v", k, err)
+		}
+		add(k, value, secretNames[k])
+	}
+	for k, path := range appInfo.EnvFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read env file for %s: %v", k, err)
+		}
+		add(k, strings.TrimRight(string(content), "\r\n"), true)
+	}
+	return result, nil
+}
+
+// newAppToken 生成应用上报状态使用的随机凭证
+func newAppToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate app token: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// redactSecrets 将字符串中出现的 NAME=value 和敏感值本身替换为占位符，空值不处理
+func redactSecrets(text string, secrets []secretValue) string {
+	for _, secret := range secrets {
+		if secret.value == "" {
+			continue
+		}
+		if secret.name != "" {
+			text = strings.ReplaceAll(text, secret.name+"="+secret.value, secret.name+"="+redactedValue)
+		}
+		text = strings.ReplaceAll(text, secret.value, redactedValue)
+	}
+	return text
+}
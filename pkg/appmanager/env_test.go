@@ -0,0 +1,143 @@
+package appmanager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"brick-smart-template/pkg/models"
+)
+
+// envNames 返回环境变量列表中的变量名，已排序
+func envNames(env []string) []string {
+	names := []string{}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envValue 返回环境变量列表中最后一次出现的值
+func envValue(env []string, name string) (string, bool) {
+	value, found := "", false
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == name {
+			value, found = v, true
+		}
+	}
+	return value, found
+}
+
+func TestEnvAllowed(t *testing.T) {
+	allowlist := []string{"PATH", "LC_*"}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"PATH", true},
+		{"PATHEXT", false},
+		{"LC_ALL", true},
+		{"LC_", true},
+		{"LANG", false},
+		{"XLC_ALL", false},
+	}
+	for _, tt := range tests {
+		if got := envAllowed(tt.name, allowlist); got != tt.want {
+			t.Errorf("envAllowed(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInheritedEnv(t *testing.T) {
+	t.Setenv("APPTEST_KEEP", "1")
+	t.Setenv("APPTEST_PREFIX_A", "2")
+	t.Setenv("APPTEST_DROP", "3")
+
+	// 默认继承proxy的全部环境变量
+	env := inheritedEnv(&models.AppInfo{})
+	if _, ok := envValue(env, "APPTEST_DROP"); !ok {
+		t.Errorf("default mode did not inherit APPTEST_DROP")
+	}
+
+	// clean_env 模式只继承白名单中的变量
+	env = inheritedEnv(&models.AppInfo{CleanEnv: true, EnvAllowlist: []string{"APPTEST_KEEP", "APPTEST_PREFIX_*"}})
+	if got, want := envNames(env), []string{"APPTEST_KEEP", "APPTEST_PREFIX_A"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("clean env = %v, want %v", got, want)
+	}
+	if env := inheritedEnv(&models.AppInfo{CleanEnv: true}); len(env) != 0 {
+		t.Errorf("clean env without allowlist = %v, want empty", env)
+	}
+}
+
+func TestBuildLaunchEnv(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	appInfo := &models.AppInfo{
+		Name:     "cleaner",
+		CleanEnv: true,
+		Env: map[string]string{
+			"ROOM":     "{{.Profile.room}}",
+			"ID":       "{{.ProxyID}}-{{.AppName}}",
+			"PASSWORD": "x",
+		},
+		EnvFiles:  map[string]string{"TOKEN": tokenFile},
+		SecretEnv: []string{"PASSWORD"},
+	}
+	data := templateData{ProxyID: "p1", AppName: "cleaner", Profile: map[string]interface{}{"room": "kitchen"}}
+
+	launch, err := buildLaunchEnv(appInfo, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"ROOM": "kitchen", "ID": "p1-cleaner", "PASSWORD": "x", "TOKEN": "file-secret"} {
+		if got, _ := envValue(launch.env, name); got != want {
+			t.Errorf("env %s = %q, want %q", name, got, want)
+		}
+	}
+	wantDisplay := map[string]string{"ROOM": "kitchen", "ID": "p1-cleaner", "PASSWORD": redactedValue, "TOKEN": redactedValue}
+	if !reflect.DeepEqual(launch.display, wantDisplay) {
+		t.Errorf("display = %v, want %v", launch.display, wantDisplay)
+	}
+
+	// 缺失的模板字段和不可读的文件视为错误
+	appInfo.Env = map[string]string{"ROOM": "{{.Profile.floor}}"}
+	if _, err := buildLaunchEnv(appInfo, data); err == nil {
+		t.Errorf("expected error for missing profile field")
+	}
+	appInfo.Env = nil
+	appInfo.EnvFiles = map[string]string{"TOKEN": filepath.Join(dir, "missing")}
+	if _, err := buildLaunchEnv(appInfo, data); err == nil {
+		t.Errorf("expected error for missing env file")
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	secrets := []secretValue{
+		{name: "PASSWORD", value: "x"},
+		{name: "EMPTY", value: ""},
+		{value: "app-token"},
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"PASSWORD=x", "PASSWORD=******"},
+		{"login with x", "login with ******"},
+		{"token app-token sent", "token ****** sent"},
+		{"EMPTY= is kept", "EMPTY= is kept"},
+		{"nothing here", "nothing here"},
+	}
+	for _, tt := range tests {
+		if got := redactSecrets(tt.text, secrets); got != tt.want {
+			t.Errorf("redactSecrets(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package appmanager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"brick-smart-template/pkg/models"
+	"brick-smart-template/pkg/policy"
+)
+
+var (
+	// ErrCommandNotFound 命令不存在（或已被淘汰）
+	ErrCommandNotFound = models.NewAPIError(http.StatusNotFound, models.ErrorCodeNotFound, "command not found")
+	// ErrCommandDone 命令已结束，不能再提交结果
+	ErrCommandDone = models.NewAPIError(http.StatusConflict, models.ErrorCodeConflict, "command already completed")
+)
+
+// invalidAppInfo 应用配置校验失败，被策略拒绝时返回 policy_violation
+func invalidAppInfo(err error) error {
+	if errors.Is(err, policy.ErrForbidden) {
+		return policyViolation(err)
+	}
+	return models.NewAPIError(http.StatusBadRequest, models.ErrorCodeInvalidAppInfo, err.Error())
+}
+
+// policyViolation 被命令白名单或签名策略拒绝
+func policyViolation(err error) error {
+	return models.NewAPIError(http.StatusForbidden, models.ErrorCodePolicyViolation, err.Error())
+}
+
+// invalidProfile profile无效或无法渲染模板
+func invalidProfile(format string, args ...interface{}) error {
+	return models.NewAPIError(http.StatusBadRequest, models.ErrorCodeInvalidProfile, fmt.Sprintf(format, args...))
+}
+
+// startFailed 启动失败，已是API错误的保持不变
+func startFailed(err error) error {
+	var apiErr *models.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, policy.ErrForbidden):
+		return policyViolation(err)
+	}
+	return models.NewAPIError(http.StatusInternalServerError, models.ErrorCodeStartFailed, err.Error())
+}
@@ -0,0 +1,110 @@
+package appmanager
+
+import (
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/models"
+)
+
+const (
+	// maxBufferedEvents 供断线重连补发的事件数量
+	maxBufferedEvents = 256
+	// subscriberBuffer 每个订阅者的事件缓冲，写满时断开订阅者
+	subscriberBuffer = 64
+)
+
+// eventBus 事件广播，生命周期/状态上报事件和应用输出各自使用独立的广播，
+// 避免大量输出把续传缓冲中的生命周期和状态事件挤出
+type eventBus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	buffer      []models.StreamEvent
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription 事件订阅，Events 关闭表示订阅结束（被取消或消费过慢被断开）
+type Subscription struct {
+	Events <-chan models.StreamEvent
+	events chan models.StreamEvent
+	bus    *eventBus
+}
+
+// newEventBus 创建事件广播
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// publish 广播事件，不会阻塞：缓冲已满的订阅者会被断开，由其按 Last-Event-ID 重连补发
+func (bus *eventBus) publish(eventType string, data interface{}) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.lastID++
+	event := models.StreamEvent{
+		ID:   bus.lastID,
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+	bus.buffer = append(bus.buffer, event)
+	if len(bus.buffer) > maxBufferedEvents {
+		bus.buffer = bus.buffer[len(bus.buffer)-maxBufferedEvents:]
+	}
+
+	for subscription := range bus.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			delete(bus.subscribers, subscription)
+			close(subscription.events)
+		}
+	}
+}
+
+// Subscribe 订阅生命周期、状态上报和告警事件，lastID 大于0时先返回缓冲中其后的事件
+// 缓冲中已没有 lastID 之后的全部事件时 complete 为false，订阅方应重新获取完整状态
+func (m *Manager) Subscribe(lastID uint64) (subscription *Subscription, replay []models.StreamEvent, complete bool) {
+	return m.events.subscribe(lastID)
+}
+
+// SubscribeLogs 订阅应用输出，事件id独立计数，续传规则与 Subscribe 相同
+func (m *Manager) SubscribeLogs(lastID uint64) (subscription *Subscription, replay []models.StreamEvent, complete bool) {
+	return m.logs.subscribe(lastID)
+}
+
+// subscribe 订阅事件
+func (bus *eventBus) subscribe(lastID uint64) (subscription *Subscription, replay []models.StreamEvent, complete bool) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	events := make(chan models.StreamEvent, subscriberBuffer)
+	subscription = &Subscription{Events: events, events: events, bus: bus}
+	bus.subscribers[subscription] = struct{}{}
+
+	complete = true
+	if lastID > 0 {
+		// proxy重启后事件id重新计数，也视为不完整
+		if lastID > bus.lastID || (len(bus.buffer) > 0 && bus.buffer[0].ID > lastID+1) {
+			complete = false
+		}
+		for _, event := range bus.buffer {
+			if event.ID > lastID {
+				replay = append(replay, event)
+			}
+		}
+	}
+	return subscription, replay, complete
+}
+
+// Close 取消订阅
+func (subscription *Subscription) Close() {
+	bus := subscription.bus
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if _, ok := bus.subscribers[subscription]; ok {
+		delete(bus.subscribers, subscription)
+		close(subscription.events)
+	}
+}
@@ -0,0 +1,36 @@
+package appmanager
+
+import (
+	"time"
+
+	"brick-smart-template/pkg/models"
+)
+
+// maxHistory 保留的生命周期事件数量
+const maxHistory = 100
+
+// recordEvent 记录生命周期事件（调用方需持有锁）
+func (m *Manager) recordEvent(event, actor, detail string) {
+	lifecycleEvent := models.LifecycleEvent{
+		Time:   time.Now(),
+		Event:  event,
+		Actor:  actor,
+		Status: m.appState.Status,
+		Detail: m.redact(detail),
+	}
+	m.history = append(m.history, lifecycleEvent)
+	if len(m.history) > maxHistory {
+		m.history = m.history[len(m.history)-maxHistory:]
+	}
+	m.events.publish(models.StreamEventLifecycle, lifecycleEvent)
+}
+
+// History 获取生命周期历史（按时间先后排列）
+func (m *Manager) History() []models.LifecycleEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	history := make([]models.LifecycleEvent, len(m.history))
+	copy(history, m.history)
+	return history
+}
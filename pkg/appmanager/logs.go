@@ -0,0 +1,62 @@
+package appmanager
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/models"
+)
+
+const (
+	// maxLogLine 单行输出的最大长度，超出部分单独作为一行
+	maxLogLine = 4096
+	// logWaitDelay 子进程退出后等待输出管道关闭的时间（孙进程可能仍持有管道）
+	logWaitDelay = 2 * time.Second
+)
+
+// logWriter 将应用输出按行发布到日志流
+type logWriter struct {
+	mu      sync.Mutex
+	m       *Manager
+	stream  string
+	secrets []secretValue // 启动时的敏感值，输出中的敏感值会被脱敏
+	buf     []byte
+}
+
+// newLogWriter 创建应用输出的写入器
+func (m *Manager) newLogWriter(stream string, secrets []secretValue) *logWriter {
+	return &logWriter{m: m, stream: stream, secrets: secrets}
+}
+
+// Write 实现 io.Writer
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			if len(w.buf) >= maxLogLine {
+				i = maxLogLine
+			} else {
+				break
+			}
+		}
+		line := bytes.TrimRight(w.buf[:i], "\r")
+		w.publish(string(line))
+		if i < len(w.buf) && w.buf[i] == '\n' {
+			i++
+		}
+		w.buf = w.buf[i:]
+	}
+	return len(p), nil
+}
+
+// publish 发布一行输出
+func (w *logWriter) publish(line string) {
+	line = redactSecrets(line, w.secrets)
+	w.m.logger.WithField("stream", w.stream).Debug(line)
+	w.m.logs.publish(models.StreamEventLog, models.LogLine{Stream: w.stream, Line: line})
+}
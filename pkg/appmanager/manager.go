@@ -2,22 +2,29 @@ package appmanager
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"time"
 
+	"brick-smart-template/pkg/alerting"
 	"brick-smart-template/pkg/models"
+	"brick-smart-template/pkg/policy"
+	"brick-smart-template/pkg/sandbox"
+	"brick-smart-template/pkg/timeseries"
 
 	"github.com/sirupsen/logrus"
-	"io/ioutil"
 )
 
 // Manager 应用管理器
 type Manager struct {
 	mu           sync.RWMutex
+	lifecycle    sync.Mutex // 串行化启动、停止和重启，等待进程退出期间不持有 mu
 	appInfo      *models.AppInfo
 	appState     *models.AppState
 	cmd          *exec.Cmd
@@ -28,108 +35,179 @@ type Manager struct {
 	internalStatus map[string]interface{} // 存储app内部状态
 	proxyID      string // 新增：proxy/app id
 	lastProfile  string // 上次启动用的 profile
+	secrets      []secretValue // 当前进程注入的敏感值，用于日志脱敏
+	sandbox      *sandbox.Command // 当前进程的沙箱（未启用时为nil）
+	policy       *policy.Policy   // 命令白名单和配置签名策略
+	appToken     string           // 当前进程上报状态使用的凭证
+	history      []models.LifecycleEvent // 生命周期历史
+	proxyEnv     map[string]string       // proxy注入子进程的连接信息（如上报地址）
+	reportSocket string                  // 应用上报状态的Unix socket路径
+	commands     *commandQueue           // 下发给应用的命令
+	events       *eventBus               // 生命周期和状态上报事件
+	logs         *eventBus               // 应用输出
+	operations   *operationStore         // 后台执行的生命周期操作
+	metrics      *models.MetricsConfig   // manifest中的指标映射
+	lastReport   time.Time               // 最近一次状态上报的时间
+	envelope     *models.StatusReport    // 最近一次上报的设备信封（不含data）
+	dataRevision uint64                  // 内部状态的版本号，每次变化递增
+	dataChanged  chan struct{}           // 内部状态变化时关闭并替换，用于长轮询
+	dataHistory  *timeseries.Store       // 内部状态字段的历史（未启用时为nil）
+	statsWindows []statsWindow           // 数值字段滚动统计的窗口
+	stats        map[string]*fieldStats  // 数值字段的滚动统计，按字段名索引
+	schemas      map[string]*models.DeviceSchema // manifest中按 device_type 声明的内部状态结构
+	defaultDeviceType string                     // 上报没有 device_type 时使用的schema（manifest的 app_name）
+	alerts       *alerting.Engine                // 告警规则引擎（未启用时为nil）
 }
 
-// NewManager 创建新的应用管理器
-func NewManager(logger *logrus.Logger, proxyID string) *Manager {
+// NewManager 创建新的应用管理器，manifest 无效时返回错误
+func NewManager(logger *logrus.Logger, proxyID string) (*Manager, error) {
 	m := &Manager{
 		appState: &models.AppState{
 			Status: "ready",
 		},
 		logger: logger,
 		proxyID: proxyID,
+		commands: newCommandQueue(),
+		events:   newEventBus(),
+		logs:     newEventBus(),
+		operations: newOperationStore(),
+		dataChanged: make(chan struct{}),
+		stats:       make(map[string]*fieldStats),
 	}
-	// 启动时尝试读取 /app/manifest.json
-	manifestPath := "/app/manifest.json"
-	if data, err := ioutil.ReadFile(manifestPath); err == nil {
-		var manifest struct {
-			AppName             string   `json:"app_name"`
-			HealthCheckInterval int      `json:"health_check_interval"`
-			DefaultArgs         []string `json:"default_args"`
-		}
-		if err := json.Unmarshal(data, &manifest); err == nil {
-			m.appInfo = &models.AppInfo{
-				Name:                manifest.AppName,
-				Command:             "./" + manifest.AppName,
-				Args:                manifest.DefaultArgs,
-				Env:                 map[string]string{},
-				AutoRestart:         false,
-				MaxRestarts:         3,
-				HealthCheckInterval: manifest.HealthCheckInterval,
-			}
+	m.statsWindows, _ = parseStatsWindows(DefaultStatsWindows)
+	// 启动时尝试读取 /app/manifest.json（或 PROXY_MANIFEST 指定的文件）
+	manifest, err := loadManifest(manifestPath())
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		m.appInfo = &models.AppInfo{
+			Name:                manifest.AppName,
+			Command:             "./" + manifest.AppName,
+			Args:                manifest.DefaultArgs,
+			Env:                 map[string]string{},
+			AutoRestart:         false,
+			MaxRestarts:         3,
+			HealthCheckInterval: manifest.HealthCheckInterval,
 		}
+		m.metrics = manifest.Metrics
+		m.loadSchemas(manifest)
+	}
+	return m, nil
+}
+
+// SetPolicy 设置应用配置的安全策略
+func (m *Manager) SetPolicy(p *policy.Policy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.policy = p
+}
+
+// SetProxyEnv 设置启动子进程时注入的proxy连接信息
+func (m *Manager) SetProxyEnv(name, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.proxyEnv == nil {
+		m.proxyEnv = make(map[string]string)
+	}
+	m.proxyEnv[name] = value
+}
+
+// SetReportSocket 设置应用上报状态的Unix socket，路径通过 PROXY_REPORT_SOCKET 告知子进程
+func (m *Manager) SetReportSocket(path string) {
+	m.SetProxyEnv("PROXY_REPORT_SOCKET", path)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reportSocket = path
+}
+
+// ConfigureAppPayload 校验 app_info 原始JSON的签名后配置应用
+func (m *Manager) ConfigureAppPayload(actor string, payload []byte, signature string) (*models.AppInfo, error) {
+	m.mu.RLock()
+	p := m.policy
+	m.mu.RUnlock()
+
+	if err := p.VerifySignature(payload, signature); err != nil {
+		return nil, policyViolation(err)
+	}
+
+	var appInfo models.AppInfo
+	if err := json.Unmarshal(payload, &appInfo); err != nil {
+		return nil, invalidAppInfo(fmt.Errorf("invalid app_info: %v", err))
+	}
+	if err := m.ConfigureApp(actor, appInfo); err != nil {
+		return nil, err
 	}
-	return m
+	return &appInfo, nil
 }
 
 // ConfigureApp 配置应用
-func (m *Manager) ConfigureApp(appInfo models.AppInfo) error {
+func (m *Manager) ConfigureApp(actor string, appInfo models.AppInfo) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if err := m.policy.CheckCommand(&appInfo); err != nil {
+		return policyViolation(err)
+	}
+	if err := m.policy.CheckEnv(&appInfo); err != nil {
+		return policyViolation(err)
+	}
+
+	if err := validateEnvPolicy(&appInfo); err != nil {
+		return invalidAppInfo(err)
+	}
+	if _, err := resolveProcessIdentity(&appInfo); err != nil {
+		return invalidAppInfo(err)
+	}
+	if err := sandbox.Validate(appInfo.Sandbox, appInfo.User != ""); err != nil {
+		return invalidAppInfo(err)
+	}
+
 	m.appInfo = &appInfo
+	m.recordEvent(models.EventConfigured, actor, "")
 	m.logger.Infof("Configured app: %s", appInfo.Name)
 	return nil
 }
 
 // StartApp 启动应用（互斥、幂等、状态检查、自动补全 -id 参数）
-func (m *Manager) StartApp(profile string) (*models.StartAppResponse, error) {
+func (m *Manager) StartApp(actor string, profile string) (*models.StartAppResponse, error) {
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if m.appInfo == nil {
-		return nil, fmt.Errorf("app not configured")
+		return nil, models.ErrNotConfigured
 	}
 
 	if m.appState.Status == models.AppStatusStarting || m.appState.Status == models.AppStatusRunning {
-		// 幂等：已在运行直接返回
-		return &models.StartAppResponse{
-			Status:  "already_running",
-			AppName: m.appInfo.Name,
-			PID:     *m.appState.PID,
-			Profile: profile,
-		}, nil
+		return nil, models.ErrAlreadyRunning.WithDetail("pid", *m.appState.PID)
 	}
 
 	// 解析profile
 	var config map[string]interface{}
 	if err := json.Unmarshal([]byte(profile), &config); err != nil {
-		return nil, fmt.Errorf("invalid JSON profile: %v", err)
+		return nil, invalidProfile("invalid JSON profile: %v", err)
 	}
 
-	// 自动补全 -id 参数
-	args := m.appInfo.Args
-	idPresent := false
-	for i, arg := range args {
-		if arg == "-id" && i+1 < len(args) {
-			args[i+1] = m.proxyID
-			idPresent = true
-		}
+	cmd, err := m.buildCommand(profile, config)
+	if err != nil {
+		return nil, startFailed(err)
 	}
-	if !idPresent {
-		args = append(args, "-id", m.proxyID)
-	}
-
-	// 设置环境变量
-	env := os.Environ()
-	for k, v := range m.appInfo.Env {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
-	}
-	env = append(env, fmt.Sprintf("APP_PROFILE=%s", profile))
-	env = append(env, fmt.Sprintf("APP_NAME=%s", m.appInfo.Name))
-	env = append(env, fmt.Sprintf("PROXY_GRPC_PORT=%s", os.Getenv("GRPC_PORT")))
-
-	cmd := exec.CommandContext(context.Background(), m.appInfo.Command, args...)
-	cmd.Env = env
-	// 不再设置 cmd.Dir
 
 	// 启动进程
-	if err := cmd.Start(); err != nil {
+	cmd, err = m.startProcess(cmd)
+	if err != nil {
 		m.appState.Status = models.AppStatusError
 		errorMsg := err.Error()
 		m.appState.LastError = &errorMsg
+		m.recordEvent(models.EventStartFailed, actor, errorMsg)
 		m.logger.Errorf("Failed to start app %s: %v", m.appInfo.Name, err)
-		return nil, err
+		return nil, startFailed(err)
 	}
 
 	m.cmd = cmd
@@ -147,6 +225,7 @@ func (m *Manager) StartApp(profile string) (*models.StartAppResponse, error) {
 	// 启动健康检查
 	m.startHealthCheck()
 
+	m.recordEvent(models.EventStarted, actor, fmt.Sprintf("pid %d", pid))
 	m.logger.Infof("Started app %s with PID %d", m.appInfo.Name, pid)
 
 	return &models.StartAppResponse{
@@ -158,61 +237,50 @@ func (m *Manager) StartApp(profile string) (*models.StartAppResponse, error) {
 }
 
 // StopApp 停止应用（互斥、幂等、状态检查）
-func (m *Manager) StopApp() (*models.StopAppResponse, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *Manager) StopApp(actor string) (*models.StopAppResponse, error) {
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
 
+	m.mu.Lock()
 	if m.cmd == nil || m.appState.Status == models.AppStatusStopped {
+		m.mu.Unlock()
 		return &models.StopAppResponse{Status: "stopped"}, nil
 	}
-
 	m.appState.Status = models.AppStatusStopping
+	cmd := m.interrupt()
+	m.mu.Unlock()
 
-	// 发送SIGTERM
-	if err := m.cmd.Process.Signal(os.Interrupt); err != nil {
-		m.logger.Errorf("Failed to send SIGTERM: %v", err)
-	}
-
-	// 等待进程结束
-	done := make(chan error, 1)
-	go func() {
-		done <- m.cmd.Wait()
-	}()
+	// 等待进程结束时不持有锁，状态查询和上报不会被阻塞
+	m.waitExit(cmd)
 
-	select {
-	case <-done:
-		// 进程正常结束
-	case <-time.After(10 * time.Second):
-		// 强制杀死
-		if err := m.cmd.Process.Kill(); err != nil {
-			m.logger.Errorf("Failed to kill process: %v", err)
-		}
-		<-done
-	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	now := time.Now()
 	m.appState.Status = models.AppStatusStopped
 	m.appState.StopTime = &now
 	m.appState.PID = nil
 
-	// 停止健康检查
-	m.stopHealthCheck()
-
-	// 停止后清空内部状态
-	m.internalStatus = nil
+	// 停止后清空内部状态，并解除基于内部状态的告警
+	m.envelope = nil
+	m.setInternalStatus(nil)
+	m.resolveAlerts(now)
 
+	m.recordEvent(models.EventStopped, actor, "")
 	m.logger.Infof("Stopped app %s", m.appInfo.Name)
 
 	return &models.StopAppResponse{Status: "stopped"}, nil
 }
 
 // RestartApp 重启应用（互斥、幂等、状态检查）
-func (m *Manager) RestartApp() (*models.RestartAppResponse, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *Manager) RestartApp(actor string) (*models.RestartAppResponse, error) {
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
 
+	m.mu.Lock()
 	if m.appInfo == nil {
-		return nil, fmt.Errorf("app not configured")
+		m.mu.Unlock()
+		return nil, models.ErrNotConfigured
 	}
 
 	profile := m.lastProfile
@@ -221,64 +289,40 @@ func (m *Manager) RestartApp() (*models.RestartAppResponse, error) {
 	}
 	var config map[string]interface{}
 	if err := json.Unmarshal([]byte(profile), &config); err != nil {
-		return nil, fmt.Errorf("invalid JSON profile: %v", err)
+		m.mu.Unlock()
+		return nil, invalidProfile("invalid JSON profile: %v", err)
 	}
 
 	// 如果应用正在运行，先停止
+	var previous *exec.Cmd
 	if m.cmd != nil && m.appState.Status != models.AppStatusStopped {
 		m.logger.Infof("Stopping app %s for restart", m.appInfo.Name)
-		if err := m.cmd.Process.Signal(os.Interrupt); err != nil {
-			m.logger.Errorf("Failed to send SIGTERM: %v", err)
-		}
-		done := make(chan error, 1)
-		go func() {
-			done <- m.cmd.Wait()
-		}()
-		select {
-		case <-done:
-			// 进程正常结束
-		case <-time.After(10 * time.Second):
-			if err := m.cmd.Process.Kill(); err != nil {
-				m.logger.Errorf("Failed to kill process: %v", err)
-			}
-			<-done
-		}
-		m.stopHealthCheck()
+		m.appState.Status = models.AppStatusStopping
+		previous = m.interrupt()
 	}
+	m.mu.Unlock()
 
-	// 自动补全 -id 参数
-	args := m.appInfo.Args
-	idPresent := false
-	for i, arg := range args {
-		if arg == "-id" && i+1 < len(args) {
-			args[i+1] = m.proxyID
-			idPresent = true
-		}
-	}
-	if !idPresent {
-		args = append(args, "-id", m.proxyID)
+	if previous != nil {
+		m.waitExit(previous)
 	}
 
-	// 设置环境变量
-	env := os.Environ()
-	for k, v := range m.appInfo.Env {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
-	}
-	env = append(env, fmt.Sprintf("APP_PROFILE=%s", profile))
-	env = append(env, fmt.Sprintf("APP_NAME=%s", m.appInfo.Name))
-	env = append(env, fmt.Sprintf("PROXY_GRPC_PORT=%s", os.Getenv("GRPC_PORT")))
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	cmd := exec.CommandContext(context.Background(), m.appInfo.Command, args...)
-	cmd.Env = env
-	// 不再设置 cmd.Dir
+	cmd, err := m.buildCommand(profile, config)
+	if err != nil {
+		return nil, startFailed(err)
+	}
 
 	// 启动进程
-	if err := cmd.Start(); err != nil {
+	cmd, err = m.startProcess(cmd)
+	if err != nil {
 		m.appState.Status = models.AppStatusError
 		errorMsg := err.Error()
 		m.appState.LastError = &errorMsg
+		m.recordEvent(models.EventStartFailed, actor, errorMsg)
 		m.logger.Errorf("Failed to restart app %s: %v", m.appInfo.Name, err)
-		return nil, err
+		return nil, startFailed(err)
 	}
 
 	m.cmd = cmd
@@ -296,6 +340,7 @@ func (m *Manager) RestartApp() (*models.RestartAppResponse, error) {
 	// 启动健康检查
 	m.startHealthCheck()
 
+	m.recordEvent(models.EventRestarted, actor, fmt.Sprintf("pid %d", pid))
 	m.logger.Infof("Restarted app %s with PID %d", m.appInfo.Name, pid)
 
 	return &models.RestartAppResponse{
@@ -313,19 +358,11 @@ func (m *Manager) GetStatus() *models.AppStatusResponse {
 
 	if m.appInfo == nil {
 		// 优先用 manifest 信息
-		manifestPath := "/app/manifest.json"
-		if data, err := ioutil.ReadFile(manifestPath); err == nil {
-			var manifest struct {
-				AppName             string   `json:"app_name"`
-				HealthCheckInterval int      `json:"health_check_interval"`
-				DefaultArgs         []string `json:"default_args"`
-			}
-			if err := json.Unmarshal(data, &manifest); err == nil {
-				return &models.AppStatusResponse{
-					AppName: manifest.AppName,
-					Status: "ready",
-					RestartCount: 0,
-				}
+		if manifest, _ := loadManifest(manifestPath()); manifest != nil {
+			return &models.AppStatusResponse{
+				AppName: manifest.AppName,
+				Status: "ready",
+				RestartCount: 0,
 			}
 		}
 		// 其次用 APP_NAME 环境变量
@@ -367,15 +404,95 @@ func (m *Manager) GetStatus() *models.AppStatusResponse {
 		RestartCount: m.appState.RestartCount,
 		LastError:   m.appState.LastError,
 		Config:      m.appState.Config,
+		Env:         m.appState.Env,
+		Sandbox:     m.appState.Sandbox,
 	}
 }
 
-// UpdateInternalStatus 更新app内部状态
-func (m *Manager) UpdateInternalStatus(status map[string]interface{}) {
+// ReportStatus 接收应用的状态上报：校验 device_id 和 device_type 对应的schema，保存设备信封并替换内部状态
+// 返回新的版本号，以及 flag 模式下被接受但未通过schema校验的字段
+func (m *Manager) ReportStatus(report models.StatusReport) (uint64, []models.SchemaViolation, error) {
+	if report.DeviceID != "" && report.DeviceID != m.proxyID {
+		return 0, nil, models.NewAPIError(http.StatusBadRequest, models.ErrorCodeIDMismatch, "device_id does not match proxy id").
+			WithDetail("device_id", report.DeviceID).
+			WithDetail("expected", m.proxyID)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	violations, err := m.validateReport(report.DeviceType, report.Data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	envelope := report
+	envelope.ReceivedAt = &now
+	envelope.Data = nil
+	envelope.Units = nil
+	envelope.Violations = violations
+	m.envelope = &envelope
+	m.lastReport = now
+	m.setInternalStatus(report.Data)
+	m.recordDataHistory(now, report.Data)
+	m.recordStats(now, report.Data)
+	m.evaluateAlerts(now, report.DeviceType, report.Data)
+	m.events.publish(models.StreamEventStatus, report.Data)
+	return m.dataRevision, violations, nil
+}
+
+// setInternalStatus 替换内部状态并递增版本号，唤醒等待新数据的长轮询，调用方需持有写锁
+func (m *Manager) setInternalStatus(status map[string]interface{}) {
 	m.internalStatus = status
+	m.dataRevision++
+	close(m.dataChanged)
+	m.dataChanged = make(chan struct{})
+}
+
+// LatestReport 获取最近一次上报的设备信封和当前内部状态（含增量更新），以及内部状态的版本号
+func (m *Manager) LatestReport() (*models.StatusReport, uint64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.latestReport(), m.dataRevision
+}
+
+// WaitLatestReport 等待内部状态的版本号达到revision，ctx结束时返回当前的上报
+func (m *Manager) WaitLatestReport(ctx context.Context, revision uint64) (*models.StatusReport, uint64) {
+	for {
+		m.mu.RLock()
+		report, current, changed := m.latestReport(), m.dataRevision, m.dataChanged
+		m.mu.RUnlock()
+
+		if current >= revision {
+			return report, current
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return report, current
+		}
+	}
+}
+
+// latestReport 组合设备信封和内部状态，调用方需持有锁
+func (m *Manager) latestReport() *models.StatusReport {
+	report := &models.StatusReport{}
+	if m.envelope != nil {
+		*report = *m.envelope
+	}
+	report.Data = m.internalStatus
+	report.Units = m.schemaUnits(report.DeviceType)
+	return report
+}
+
+// LastReportTime 最近一次状态上报的时间，尚未收到上报时为零值
+func (m *Manager) LastReportTime() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.lastReport
 }
 
 // GetInternalStatus 获取app内部状态
@@ -386,15 +503,152 @@ func (m *Manager) GetInternalStatus() map[string]interface{} {
 	return m.internalStatus
 }
 
+// buildCommand 构建子进程命令（渲染模板、自动补全 -id 参数、按策略设置环境变量）
+func (m *Manager) buildCommand(profile string, config map[string]interface{}) (*exec.Cmd, error) {
+	// 启动时再次校验，防止配置后可执行文件被替换
+	if err := m.policy.CheckCommand(m.appInfo); err != nil {
+		return nil, err
+	}
+	if err := m.policy.CheckEnv(m.appInfo); err != nil {
+		return nil, err
+	}
+
+	data := templateData{
+		ProxyID: m.proxyID,
+		AppName: m.appInfo.Name,
+		Profile: config,
+	}
+
+	// 渲染参数模板（复制一份，避免修改配置本身）
+	args := make([]string, 0, len(m.appInfo.Args)+2)
+	for _, arg := range m.appInfo.Args {
+		rendered, err := renderTemplate(arg, data)
+		if err != nil {
+			return nil, invalidProfile("failed to render arg %q: %v", arg, err)
+		}
+		args = append(args, rendered)
+	}
+
+	// 自动补全 -id 参数
+	idPresent := false
+	for i, arg := range args {
+		if arg == "-id" && i+1 < len(args) {
+			args[i+1] = m.proxyID
+			idPresent = true
+		}
+	}
+	if !idPresent {
+		args = append(args, "-id", m.proxyID)
+	}
+
+	// 设置环境变量
+	launch, err := buildLaunchEnv(m.appInfo, data)
+	if err != nil {
+		return nil, err
+	}
+	env := launch.env
+	env = append(env, fmt.Sprintf("APP_PROFILE=%s", profile))
+	env = append(env, fmt.Sprintf("APP_NAME=%s", m.appInfo.Name))
+	for k, v := range m.proxyEnv {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	// 每次启动生成新的上报凭证
+	appToken, err := newAppToken()
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, fmt.Sprintf("PROXY_APP_TOKEN=%s", appToken))
+
+	identity, err := resolveProcessIdentity(m.appInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	// socket目录默认只有proxy可以进入，以其他用户运行的应用需要授权
+	if m.reportSocket != "" && identity.uid != nil {
+		if err := shareReportSocket(m.reportSocket, identity.credential().GID); err != nil {
+			m.logger.Warnf("Failed to share report socket with app %s: %v", m.appInfo.Name, err)
+		}
+	}
+
+	cmd := exec.CommandContext(context.Background(), m.appInfo.Command, args...)
+	cmd.Env = env
+
+	m.secrets = append(launch.secrets, secretValue{value: appToken})
+	// 应用输出发布到日志流；子进程退出后最多等待输出管道关闭 logWaitDelay
+	cmd.Stdout = m.newLogWriter("stdout", m.secrets)
+	cmd.Stderr = m.newLogWriter("stderr", m.secrets)
+	cmd.WaitDelay = logWaitDelay
+	m.appToken = appToken
+	m.appState.Env = launch.display
+	m.logger.Infof("Launching app %s: %s", m.appInfo.Name, m.redact(strings.Join(cmd.Args, " ")))
+
+	// 启用沙箱时由沙箱初始化进程完成身份切换
+	m.sandbox = nil
+	m.appState.Sandbox = nil
+	if m.appInfo.Sandbox != nil {
+		cmd.Dir = identity.dir
+		sandboxed, err := sandbox.New(cmd, m.appInfo.Sandbox, identity.credential(), identity.umask)
+		if err != nil {
+			return nil, err
+		}
+		m.sandbox = sandboxed
+		m.appState.Sandbox = sandboxed.Config
+		return sandboxed.Cmd, nil
+	}
+
+	if err := applyProcessIdentity(cmd, identity); err != nil {
+		return nil, err
+	}
+	if identity.umask != nil {
+		return sandbox.WithUmask(cmd, *identity.umask)
+	}
+	return cmd, nil
+}
+
+// startProcess 按运行身份启动子进程，无权限创建沙箱命名空间时降级重试
+func (m *Manager) startProcess(cmd *exec.Cmd) (*exec.Cmd, error) {
+	err := cmd.Start()
+	if err != nil && m.sandbox != nil && m.sandbox.HasNamespaces() && sandbox.IsPermissionError(err) {
+		if degradeErr := m.sandbox.Degrade(); degradeErr != nil {
+			return nil, fmt.Errorf("%v (%v)", err, degradeErr)
+		}
+		m.logger.Warnf("Namespaces not permitted for app %s, starting without them: %v", m.appInfo.Name, err)
+		m.appState.Sandbox = m.sandbox.Config
+		cmd = m.sandbox.Cmd
+		err = cmd.Start()
+	}
+	return cmd, err
+}
+
+// VerifyAppToken 校验应用上报状态使用的凭证
+func (m *Manager) VerifyAppToken(token string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.appToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(m.appToken)) == 1
+}
+
+// redact 对日志内容中的敏感值脱敏
+func (m *Manager) redact(text string) string {
+	return redactSecrets(text, m.secrets)
+}
+
 // startHealthCheck 启动健康检查
 func (m *Manager) startHealthCheck() {
 	if m.healthTicker != nil {
 		m.healthTicker.Stop()
 	}
 
-	m.healthTicker = time.NewTicker(time.Duration(m.appInfo.HealthCheckInterval) * time.Second)
+	// goroutine 持有自己的 ticker，stopHealthCheck 清空字段时不会读到nil
+	ticker := time.NewTicker(time.Duration(m.appInfo.HealthCheckInterval) * time.Second)
+	m.healthTicker = ticker
 	go func() {
-		for range m.healthTicker.C {
+		for range ticker.C {
 			m.checkHealth()
 		}
 	}()
@@ -408,6 +662,36 @@ func (m *Manager) stopHealthCheck() {
 	}
 }
 
+// interrupt 向当前进程发送SIGTERM并停止健康检查，返回待等待退出的进程（调用方需持有 mu）
+func (m *Manager) interrupt() *exec.Cmd {
+	cmd := m.cmd
+	m.cmd = nil
+	m.stopHealthCheck()
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		m.logger.Errorf("Failed to send SIGTERM: %v", err)
+	}
+	return cmd
+}
+
+// waitExit 等待进程退出，超时后强制杀死（调用方不能持有 mu）
+func (m *Manager) waitExit(cmd *exec.Cmd) {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case <-done:
+		// 进程正常结束
+	case <-time.After(10 * time.Second):
+		// 强制杀死
+		if err := cmd.Process.Kill(); err != nil {
+			m.logger.Errorf("Failed to kill process: %v", err)
+		}
+		<-done
+	}
+}
+
 // checkHealth 健康检查
 func (m *Manager) checkHealth() {
 	m.mu.Lock()
@@ -420,41 +704,48 @@ func (m *Manager) checkHealth() {
 	// 检查进程是否还在运行
 	if m.cmd.ProcessState != nil && m.cmd.ProcessState.Exited() {
 		if m.appInfo.AutoRestart && m.appState.RestartCount < m.appInfo.MaxRestarts {
+			m.recordEvent(models.EventCrashed, models.ActorProxy, "auto restart")
 			m.logger.Infof("App %s crashed, restarting... (attempt %d/%d)", m.appInfo.Name, m.appState.RestartCount+1, m.appInfo.MaxRestarts)
 			go m.restartApp()
 		} else {
 			m.appState.Status = models.AppStatusStopped
 			now := time.Now()
 			m.appState.StopTime = &now
+			m.recordEvent(models.EventCrashed, models.ActorProxy, "max restarts reached")
 			m.logger.Errorf("App %s crashed and max restarts reached (%d/%d)", m.appInfo.Name, m.appState.RestartCount, m.appInfo.MaxRestarts)
 		}
 	} else if m.appState.Status == models.AppStatusStarting {
 		m.appState.Status = models.AppStatusRunning
+		m.recordEvent(models.EventRunning, models.ActorProxy, "")
 		m.logger.Infof("App %s is now running", m.appInfo.Name)
 	}
 }
 
 // restartApp 重启应用
 func (m *Manager) restartApp() {
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// 等待期间应用已被停止或重启
+	if m.cmd == nil || m.cmd.ProcessState == nil {
+		return
+	}
+
 	m.appState.RestartCount++
 	m.appState.Status = models.AppStatusStarting
 
 	// 启动新进程
-	args := append([]string{m.appInfo.Command}, m.appInfo.Args...)
-	cmd := exec.CommandContext(context.Background(), args[0], args[1:]...)
-	cmd.Env = os.Environ()
-	for k, v := range m.appInfo.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	cmd, err := m.buildCommand(m.lastProfile, m.appState.Config)
+	if err == nil {
+		cmd, err = m.startProcess(cmd)
 	}
-	// 不再设置 cmd.Dir
-
-	if err := cmd.Start(); err != nil {
+	if err != nil {
 		m.appState.Status = models.AppStatusError
 		errorMsg := err.Error()
 		m.appState.LastError = &errorMsg
+		m.recordEvent(models.EventStartFailed, models.ActorProxy, errorMsg)
 		m.logger.Errorf("Failed to restart app: %v", err)
 		return
 	}
@@ -466,9 +757,35 @@ func (m *Manager) restartApp() {
 	m.appState.StartTime = &now
 	m.appState.LastError = nil
 
+	m.recordEvent(models.EventRestarted, models.ActorProxy, fmt.Sprintf("pid %d", pid))
 	m.logger.Infof("Restarted app %s with PID %d", m.appInfo.Name, pid)
 }
 
+// AppPID 获取当前应用进程的PID，未运行时返回0
+func (m *Manager) AppPID() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.appState.PID == nil {
+		return 0
+	}
+	return *m.appState.PID
+}
+
+// CheckTarget 校验请求中的id和app_name与proxy一致，为空时不校验
+func (m *Manager) CheckTarget(id, appName string) error {
+	if id != "" && id != m.proxyID {
+		return models.NewAPIError(http.StatusBadRequest, models.ErrorCodeIDMismatch, "id mismatch: expected "+m.proxyID).
+			WithDetail("expected", m.proxyID)
+	}
+	proxyAppName := m.GetStatus().AppName
+	if appName != "" && proxyAppName != "" && appName != proxyAppName {
+		return models.NewAPIError(http.StatusBadRequest, models.ErrorCodeAppNameMismatch, "app_name mismatch: expected "+proxyAppName).
+			WithDetail("expected", proxyAppName)
+	}
+	return nil
+}
+
 func (m *Manager) ProxyID() string {
 	return m.proxyID
 } 
\ No newline at end of file
@@ -0,0 +1,110 @@
+package appmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/prometheus/common/model"
+)
+
+// defaultManifestPath 镜像中应用的描述文件，可通过环境变量 PROXY_MANIFEST 覆盖
+const defaultManifestPath = "/app/manifest.json"
+
+// manifestPath 应用描述文件的路径
+func manifestPath() string {
+	if path := os.Getenv("PROXY_MANIFEST"); path != "" {
+		return path
+	}
+	return defaultManifestPath
+}
+
+// manifest 应用描述文件
+type manifest struct {
+	AppName             string                          `json:"app_name"`
+	HealthCheckInterval int                             `json:"health_check_interval"`
+	DefaultArgs         []string                        `json:"default_args"`
+	Metrics             *models.MetricsConfig           `json:"metrics"`
+	Schemas             map[string]*models.DeviceSchema `json:"schemas"` // 按 device_type 声明的内部状态结构
+}
+
+// builtinMetricNames proxy自身导出的应用指标，manifest中的指标名不能与之重复
+var builtinMetricNames = map[string]bool{
+	"brick_app_status":                  true,
+	"brick_app_restarts_total":          true,
+	"brick_app_uptime_seconds":          true,
+	"brick_app_last_report_age_seconds": true,
+	"brick_app_data":                    true,
+}
+
+// reservedMetricPrefixes proxy自身和Go运行时指标的前缀
+var reservedMetricPrefixes = []string{"brick_proxy_", "brick_http_", "go_", "process_", "promhttp_"}
+
+// loadManifest 读取应用描述文件，文件不存在时返回nil，内容无效时返回错误
+func loadManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var result manifest
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	if err := checkMetrics(result.Metrics); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	if err := checkSchemas(result.Schemas); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	return &result, nil
+}
+
+// checkMetrics 检查指标映射：指标名有效、不与内置指标冲突，且不会导出重复的时间序列
+func checkMetrics(config *models.MetricsConfig) error {
+	if config == nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	fields := make(map[string]bool)
+	for _, field := range config.Fields {
+		if field.Field == "" {
+			return fmt.Errorf("metrics: field is required")
+		}
+		if field.Name == "" {
+			// 导出为 brick_app_data{field="..."}
+			if fields[field.Field] {
+				return fmt.Errorf("metrics: field %s is exported twice as brick_app_data", field.Field)
+			}
+			fields[field.Field] = true
+			continue
+		}
+		if !model.IsValidMetricName(model.LabelValue(field.Name)) {
+			return fmt.Errorf("metrics: invalid metric name %q", field.Name)
+		}
+		if builtinMetricNames[field.Name] {
+			return fmt.Errorf("metrics: metric name %s is reserved by the proxy", field.Name)
+		}
+		for _, prefix := range reservedMetricPrefixes {
+			if strings.HasPrefix(field.Name, prefix) {
+				return fmt.Errorf("metrics: metric name %s uses reserved prefix %s", field.Name, prefix)
+			}
+		}
+		if names[field.Name] {
+			return fmt.Errorf("metrics: duplicate metric name %s", field.Name)
+		}
+		names[field.Name] = true
+	}
+	return nil
+}
+
+// MetricsConfig 获取manifest中的指标映射，未配置时返回nil
+func (m *Manager) MetricsConfig() *models.MetricsConfig {
+	return m.metrics
+}
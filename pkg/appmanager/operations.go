@@ -0,0 +1,116 @@
+package appmanager
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/models"
+)
+
+// maxOperations 保留的操作数量
+const maxOperations = 100
+
+// ErrOperationNotFound 操作不存在（或已被淘汰）
+var ErrOperationNotFound = models.NewAPIError(http.StatusNotFound, models.ErrorCodeNotFound, "operation not found")
+
+// operationStore 后台执行的生命周期操作
+type operationStore struct {
+	mu         sync.Mutex
+	operations map[string]*operation
+	order      []string
+}
+
+// operation 操作及其完成通知
+type operation struct {
+	models.Operation
+	done chan struct{}
+}
+
+// newOperationStore 创建操作存储
+func newOperationStore() *operationStore {
+	return &operationStore{operations: make(map[string]*operation)}
+}
+
+// RunOperation 在后台执行生命周期操作，立即返回操作状态
+func (m *Manager) RunOperation(operationType, actor string, run func() (interface{}, error)) (*models.Operation, error) {
+	id, err := newCommandID()
+	if err != nil {
+		return nil, err
+	}
+	op := &operation{
+		Operation: models.Operation{
+			ID:        id,
+			Type:      operationType,
+			Status:    models.OperationStatusRunning,
+			Actor:     actor,
+			CreatedAt: time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+
+	store := m.operations
+	store.mu.Lock()
+	store.operations[id] = op
+	store.order = append(store.order, id)
+	for len(store.order) > maxOperations {
+		delete(store.operations, store.order[0])
+		store.order = store.order[1:]
+	}
+	snapshot := op.Operation
+	store.mu.Unlock()
+
+	go func() {
+		result, err := run()
+		now := time.Now()
+
+		store.mu.Lock()
+		op.CompletedAt = &now
+		if err != nil {
+			op.Status = models.OperationStatusFailed
+			op.Error = models.AsAPIError(err)
+		} else {
+			op.Status = models.OperationStatusSucceeded
+			op.Result = result
+		}
+		store.mu.Unlock()
+		close(op.done)
+	}()
+
+	return &snapshot, nil
+}
+
+// GetOperation 获取操作状态
+func (m *Manager) GetOperation(id string) (*models.Operation, error) {
+	store := m.operations
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	op, ok := store.operations[id]
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+	snapshot := op.Operation
+	return &snapshot, nil
+}
+
+// WaitOperation 等待操作完成，ctx结束时返回当时的操作状态
+func (m *Manager) WaitOperation(ctx context.Context, id string) (*models.Operation, error) {
+	store := m.operations
+	store.mu.Lock()
+	op, ok := store.operations[id]
+	store.mu.Unlock()
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+
+	select {
+	case <-op.done:
+	case <-ctx.Done():
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	snapshot := op.Operation
+	return &snapshot, nil
+}
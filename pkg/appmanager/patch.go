@@ -0,0 +1,288 @@
+package appmanager
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"brick-smart-template/pkg/models"
+)
+
+// MergePatchInternalStatus 以 RFC 7386 JSON Merge Patch 原子地更新app内部状态，返回新的版本号和未通过schema校验的字段
+func (m *Manager) MergePatchInternalStatus(patch map[string]interface{}) (uint64, []models.SchemaViolation, error) {
+	return m.patchInternalStatus(func(current map[string]interface{}) (map[string]interface{}, error) {
+		return mergePatch(current, patch).(map[string]interface{}), nil
+	})
+}
+
+// JSONPatchInternalStatus 以 RFC 6902 JSON Patch 原子地更新app内部状态，任一操作失败时不做任何修改
+func (m *Manager) JSONPatchInternalStatus(operations []models.JSONPatchOperation) (uint64, []models.SchemaViolation, error) {
+	return m.patchInternalStatus(func(current map[string]interface{}) (map[string]interface{}, error) {
+		var doc interface{} = current
+		for i, operation := range operations {
+			var err error
+			if doc, err = applyPatchOperation(doc, operation); err != nil {
+				return nil, models.NewAPIError(http.StatusConflict, models.ErrorCodeConflict, err.Error()).
+					WithDetail("operation", i)
+			}
+		}
+		result, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, models.InvalidRequest("internal status must remain a JSON object")
+		}
+		return result, nil
+	})
+}
+
+// patchInternalStatus 在写锁内对内部状态的副本执行修改，修改后的状态按最近一次上报的 device_type 校验schema，成功后替换并发布状态事件
+func (m *Manager) patchInternalStatus(apply func(map[string]interface{}) (map[string]interface{}, error)) (uint64, []models.SchemaViolation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, _ := deepCopy(m.internalStatus).(map[string]interface{})
+	if current == nil {
+		current = make(map[string]interface{})
+	}
+	status, err := apply(current)
+	if err != nil {
+		return 0, nil, err
+	}
+	var deviceType string
+	if m.envelope != nil {
+		deviceType = m.envelope.DeviceType
+	}
+	violations, err := m.validateReport(deviceType, status)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	if m.envelope != nil {
+		m.envelope.ReceivedAt = &now
+		m.envelope.Violations = violations
+	}
+	m.setInternalStatus(status)
+	m.recordDataHistory(now, status)
+	m.recordStats(now, status)
+	m.evaluateAlerts(now, deviceType, status)
+	m.lastReport = now
+	m.events.publish(models.StreamEventStatus, status)
+	return m.dataRevision, violations, nil
+}
+
+// mergePatch 按 RFC 7386 合并：对象逐字段合并，null 删除字段，其他值整体替换
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// deepCopy 复制JSON解码得到的值（对象、数组和标量）
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = deepCopy(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = deepCopy(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// applyPatchOperation 执行一个 RFC 6902 操作，返回修改后的文档
+func applyPatchOperation(doc interface{}, operation models.JSONPatchOperation) (interface{}, error) {
+	switch operation.Op {
+	case "add":
+		return setPointer(doc, operation.Path, deepCopy(operation.Value), true)
+	case "remove":
+		doc, _, err := removePointer(doc, operation.Path)
+		return doc, err
+	case "replace":
+		if _, err := getPointer(doc, operation.Path); err != nil {
+			return nil, err
+		}
+		return setPointer(doc, operation.Path, deepCopy(operation.Value), false)
+	case "move":
+		if operation.Path == operation.From || strings.HasPrefix(operation.Path, operation.From+"/") {
+			if operation.Path == operation.From {
+				return doc, nil
+			}
+			return nil, fmt.Errorf("cannot move %q into itself", operation.From)
+		}
+		doc, value, err := removePointer(doc, operation.From)
+		if err != nil {
+			return nil, err
+		}
+		return setPointer(doc, operation.Path, value, true)
+	case "copy":
+		value, err := getPointer(doc, operation.From)
+		if err != nil {
+			return nil, err
+		}
+		return setPointer(doc, operation.Path, deepCopy(value), true)
+	case "test":
+		value, err := getPointer(doc, operation.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, operation.Value) {
+			return nil, fmt.Errorf("test failed at %q", operation.Path)
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unsupported op %q", operation.Op)
+}
+
+// parsePointer 解析 RFC 6901 JSON Pointer
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex 解析数组下标，allowEnd 时 "-" 和 len 表示末尾
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > length || (!allowEnd && index == length) {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+// getPointer 读取指针指向的值
+func getPointer(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			value, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q does not exist", pointer)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[index]
+		default:
+			return nil, fmt.Errorf("path %q does not exist", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// setPointer 在指针位置写入值，insert 为true时数组按插入处理（add），否则替换（replace）
+func setPointer(doc interface{}, pointer string, value interface{}, insert bool) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := getPointer(doc, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		v[last] = value
+		return doc, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(v), insert)
+		if err != nil {
+			return nil, err
+		}
+		if !insert {
+			v[index] = value
+			return doc, nil
+		}
+		v = append(v, nil)
+		copy(v[index+1:], v[index:])
+		v[index] = value
+		return setPointer(doc, parentPointer, v, false)
+	}
+	return nil, fmt.Errorf("path %q does not exist", pointer)
+}
+
+// removePointer 删除指针指向的值，返回修改后的文档和被删除的值
+func removePointer(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document")
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := getPointer(doc, parentPointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		value, ok := v[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("path %q does not exist", pointer)
+		}
+		delete(v, last)
+		return doc, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(v), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := v[index]
+		v = append(v[:index:index], v[index+1:]...)
+		doc, err = setPointer(doc, parentPointer, v, false)
+		return doc, value, err
+	}
+	return nil, nil, fmt.Errorf("path %q does not exist", pointer)
+}
@@ -0,0 +1,116 @@
+package appmanager
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"brick-smart-template/pkg/models"
+)
+
+// decodeJSON 解码测试用例中的JSON文本
+func decodeJSON(t *testing.T, text string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatalf("invalid JSON %s: %v", text, err)
+	}
+	return value
+}
+
+// TestApplyPatchOperation 用例主要取自 RFC 6902 附录A
+func TestApplyPatchOperation(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		operations string
+		want       string // 为空表示期望失败
+	}{
+		{"add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"add to array end with dash", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`},
+		{"add at array length", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/1","value":"baz"}]`, `{"foo":["bar","baz"]}`},
+		{"add past array end", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"baz"}]`, ``},
+		{"add nested array", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"add to nonexistent parent", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``},
+		{"add replaces existing member", `{"foo":"bar"}`, `[{"op":"add","path":"/foo","value":1}]`, `{"foo":1}`},
+		{"remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"remove missing member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ``},
+		{"remove dash index", `{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/-"}]`, ``},
+		{"replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"replace missing member", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, ``},
+		{"replace array element", `{"foo":[1,2]}`, `[{"op":"replace","path":"/foo/1","value":3}]`, `{"foo":[1,3]}`},
+		{"replace at array length", `{"foo":[1,2]}`, `[{"op":"replace","path":"/foo/2","value":3}]`, ``},
+		{"move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"move into itself", `{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar"}]`, ``},
+		{"move to same path", `{"foo":1}`, `[{"op":"move","from":"/foo","path":"/foo"}]`, `{"foo":1}`},
+		{"copy value is independent", `{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`, `{"foo":{"bar":1},"baz":{"bar":2}}`},
+		{"test success", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"test failure", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``},
+		{"test string is not number", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ``},
+		{"escaped pointer", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"replace","path":"/~1","value":8}]`, `{"/":8,"~1":10}`},
+		{"empty key", `{"":1}`, `[{"op":"replace","path":"/","value":2}]`, `{"":2}`},
+		{"leading zero index", `{"foo":[1,2]}`, `[{"op":"remove","path":"/foo/01"}]`, ``},
+		{"negative index", `{"foo":[1,2]}`, `[{"op":"remove","path":"/foo/-1"}]`, ``},
+		{"pointer without slash", `{"foo":1}`, `[{"op":"remove","path":"foo"}]`, ``},
+		{"remove whole document", `{"foo":1}`, `[{"op":"remove","path":""}]`, ``},
+		{"unsupported op", `{"foo":1}`, `[{"op":"increment","path":"/foo"}]`, ``},
+		{"nested array in array", `{"foo":[[1],[2]]}`, `[{"op":"add","path":"/foo/1/0","value":3}]`, `{"foo":[[1],[3,2]]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var operations []models.JSONPatchOperation
+			if err := json.Unmarshal([]byte(tt.operations), &operations); err != nil {
+				t.Fatal(err)
+			}
+			doc := decodeJSON(t, tt.doc)
+			var err error
+			for _, operation := range operations {
+				if doc, err = applyPatchOperation(doc, operation); err != nil {
+					break
+				}
+			}
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("expected error, got %v", doc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := decodeJSON(t, tt.want); !reflect.DeepEqual(doc, want) {
+				t.Fatalf("got %v, want %v", doc, want)
+			}
+		})
+	}
+}
+
+// TestMergePatch 用例取自 RFC 7386 附录A
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got := mergePatch(decodeJSON(t, tt.target), decodeJSON(t, tt.patch))
+		if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("mergePatch(%s, %s) = %v, want %v", tt.target, tt.patch, got, want)
+		}
+	}
+}
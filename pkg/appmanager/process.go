@@ -0,0 +1,151 @@
+package appmanager
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"brick-smart-template/pkg/models"
+	"brick-smart-template/pkg/sandbox"
+)
+
+// processIdentity 子进程的运行身份（解析后的uid/gid/umask）
+type processIdentity struct {
+	dir    string
+	uid    *uint32
+	gid    *uint32
+	groups []uint32
+	umask  *int
+}
+
+// resolveProcessIdentity 解析并校验工作目录、用户、组和umask
+func resolveProcessIdentity(appInfo *models.AppInfo) (*processIdentity, error) {
+	identity := &processIdentity{dir: appInfo.WorkingDir}
+
+	if appInfo.WorkingDir != "" {
+		info, err := os.Stat(appInfo.WorkingDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid working_dir: %v", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid working_dir: %s is not a directory", appInfo.WorkingDir)
+		}
+	}
+
+	if appInfo.User != "" {
+		u, err := lookupUser(appInfo.User)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user %q: %v", appInfo.User, err)
+		}
+		uid, err := parseID(u.Uid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid for user %q: %v", appInfo.User, err)
+		}
+		identity.uid = &uid
+		// 未指定组时使用用户的主组
+		if appInfo.Group == "" {
+			gid, err := parseID(u.Gid)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gid for user %q: %v", appInfo.User, err)
+			}
+			identity.gid = &gid
+		}
+	}
+
+	if appInfo.Group != "" {
+		gid, err := resolveGroup(appInfo.Group)
+		if err != nil {
+			return nil, err
+		}
+		identity.gid = &gid
+	}
+
+	for _, name := range appInfo.SupplementaryGroups {
+		gid, err := resolveGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		identity.groups = append(identity.groups, gid)
+	}
+
+	if (identity.gid != nil || len(identity.groups) > 0) && identity.uid == nil {
+		return nil, fmt.Errorf("group settings require user")
+	}
+
+	if appInfo.Umask != "" {
+		umask, err := strconv.ParseUint(appInfo.Umask, 8, 32)
+		if err != nil || umask > 0777 {
+			return nil, fmt.Errorf("invalid umask %q: must be octal between 0000 and 0777", appInfo.Umask)
+		}
+		value := int(umask)
+		identity.umask = &value
+	}
+
+	return identity, nil
+}
+
+// credential 转换为沙箱内切换的运行身份，未指定用户时返回nil
+func (identity *processIdentity) credential() *sandbox.Credential {
+	if identity.uid == nil {
+		return nil
+	}
+	credential := &sandbox.Credential{
+		UID:    *identity.uid,
+		GID:    *identity.uid,
+		Groups: identity.groups,
+	}
+	if identity.gid != nil {
+		credential.GID = *identity.gid
+	}
+	return credential
+}
+
+// lookupUser 按用户名或uid查找用户
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+		// 纯数字uid允许不存在于 /etc/passwd 中
+		return &user.User{Uid: name, Gid: name}, nil
+	}
+	return user.Lookup(name)
+}
+
+// resolveGroup 按组名或gid解析组
+func resolveGroup(name string) (uint32, error) {
+	if gid, err := parseID(name); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("invalid group %q: %v", name, err)
+	}
+	return parseID(g.Gid)
+}
+
+// parseID 解析数字形式的uid/gid
+func parseID(id string) (uint32, error) {
+	value, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(value), nil
+}
+
+// shareReportSocket 应用以其他用户运行时，把上报socket及其目录授权给应用的组
+func shareReportSocket(path string, gid uint32) error {
+	dir := filepath.Dir(path)
+	if err := os.Chown(dir, -1, int(gid)); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0710); err != nil {
+		return err
+	}
+	if err := os.Chown(path, -1, int(gid)); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0660)
+}
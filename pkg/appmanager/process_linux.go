@@ -0,0 +1,31 @@
+//go:build linux
+
+package appmanager
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// applyProcessIdentity 设置子进程的工作目录和运行身份
+func applyProcessIdentity(cmd *exec.Cmd, identity *processIdentity) error {
+	cmd.Dir = identity.dir
+	if identity.uid == nil {
+		return nil
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	credential := &syscall.Credential{
+		Uid:    *identity.uid,
+		Groups: identity.groups,
+	}
+	if identity.gid != nil {
+		credential.Gid = *identity.gid
+	} else {
+		credential.Gid = *identity.uid
+	}
+	// NoSetGroups为false时，未指定附加组会被清空，避免继承proxy(root)的附加组
+	cmd.SysProcAttr.Credential = credential
+	return nil
+}
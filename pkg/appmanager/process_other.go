@@ -0,0 +1,20 @@
+//go:build !linux
+
+package appmanager
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// applyProcessIdentity 非Linux平台仅支持设置工作目录
+func applyProcessIdentity(cmd *exec.Cmd, identity *processIdentity) error {
+	cmd.Dir = identity.dir
+	if identity.uid != nil {
+		return fmt.Errorf("user switching is only supported on linux")
+	}
+	if identity.umask != nil {
+		return fmt.Errorf("umask is only supported on linux")
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package appmanager
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"brick-smart-template/pkg/models"
+)
+
+// schemaTypes manifest schema 支持的字段类型
+var schemaTypes = map[string]bool{"number": true, "integer": true, "string": true, "boolean": true, "object": true, "array": true}
+
+// checkSchema 检查manifest中的schema是否有效
+func checkSchema(schema *models.DeviceSchema) error {
+	switch schema.Validation {
+	case "", models.SchemaValidationReject, models.SchemaValidationFlag:
+	default:
+		return fmt.Errorf("invalid validation %q", schema.Validation)
+	}
+	for name, field := range schema.Fields {
+		if !schemaTypes[field.Type] {
+			return fmt.Errorf("field %s: invalid type %q", name, field.Type)
+		}
+		if (field.Min != nil || field.Max != nil) && field.Type != "number" && field.Type != "integer" {
+			return fmt.Errorf("field %s: min/max only apply to number and integer", name)
+		}
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			return fmt.Errorf("field %s: min is greater than max", name)
+		}
+	}
+	return nil
+}
+
+// checkSchemas 检查manifest中按 device_type 声明的schema
+func checkSchemas(schemas map[string]*models.DeviceSchema) error {
+	for deviceType, schema := range schemas {
+		if deviceType == "" {
+			return fmt.Errorf("schemas: device type is required")
+		}
+		if schema == nil {
+			return fmt.Errorf("schemas: %s: schema is empty", deviceType)
+		}
+		if err := checkSchema(schema); err != nil {
+			return fmt.Errorf("schemas: %s: %v", deviceType, err)
+		}
+	}
+	return nil
+}
+
+// loadSchemas 设置manifest中已检查过的schema，上报没有 device_type 时使用 app_name 对应的schema
+func (m *Manager) loadSchemas(manifest *manifest) {
+	m.schemas = manifest.Schemas
+	m.defaultDeviceType = manifest.AppName
+}
+
+// schemaFor 返回上报应使用的schema（调用方需持有锁）
+// manifest 没有声明schema时不校验；声明了schema时，没有 device_type 的上报使用 app_name 对应的schema，找不到对应schema的上报被拒绝
+func (m *Manager) schemaFor(deviceType string) (*models.DeviceSchema, error) {
+	if len(m.schemas) == 0 {
+		return nil, nil
+	}
+	resolved := deviceType
+	if resolved == "" {
+		resolved = m.defaultDeviceType
+	}
+	if schema, ok := m.schemas[resolved]; ok {
+		return schema, nil
+	}
+	if deviceType == "" {
+		return nil, models.NewAPIError(http.StatusUnprocessableEntity, models.ErrorCodeSchemaViolation, "device_type is required")
+	}
+	return nil, models.NewAPIError(http.StatusUnprocessableEntity, models.ErrorCodeSchemaViolation, "no schema for device_type").
+		WithDetail("device_type", deviceType)
+}
+
+// validateReport 按 device_type 对应的schema校验内部状态（调用方需持有锁）
+// reject 模式下不通过时返回 schema_violation，flag 模式下返回不通过的字段
+func (m *Manager) validateReport(deviceType string, data map[string]interface{}) ([]models.SchemaViolation, error) {
+	schema, err := m.schemaFor(deviceType)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	violations := validateData(schema, data)
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	if schema.Validation == models.SchemaValidationFlag {
+		m.logger.Warnf("Status report does not match schema of %s: %d invalid fields", deviceType, len(violations))
+		return violations, nil
+	}
+	return nil, models.NewAPIError(http.StatusUnprocessableEntity, models.ErrorCodeSchemaViolation, "status report does not match schema").
+		WithDetail("device_type", deviceType).
+		WithDetail("violations", violations)
+}
+
+// validateData 返回不符合schema的字段，按字段名排序
+func validateData(schema *models.DeviceSchema, data map[string]interface{}) []models.SchemaViolation {
+	var violations []models.SchemaViolation
+	for name, field := range schema.Fields {
+		value, ok := data[name]
+		if !ok || value == nil {
+			if field.Required {
+				violations = append(violations, models.SchemaViolation{Field: name, Message: "required field is missing"})
+			}
+			continue
+		}
+		if message := validateField(field, value); message != "" {
+			violations = append(violations, models.SchemaViolation{Field: name, Message: message})
+		}
+	}
+	if !schema.AdditionalFields {
+		for name := range data {
+			if _, ok := schema.Fields[name]; !ok {
+				violations = append(violations, models.SchemaViolation{Field: name, Message: "field is not declared in schema"})
+			}
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Field < violations[j].Field })
+	return violations
+}
+
+// validateField 校验一个字段的类型、范围和枚举值，通过时返回空字符串
+func validateField(field models.FieldSchema, value interface{}) string {
+	switch field.Type {
+	case "number", "integer":
+		number, ok := value.(float64)
+		if !ok {
+			return fmt.Sprintf("expected %s", field.Type)
+		}
+		if field.Type == "integer" && number != math.Trunc(number) {
+			return "expected integer"
+		}
+		if field.Min != nil && number < *field.Min {
+			return fmt.Sprintf("%v is less than minimum %v", number, *field.Min)
+		}
+		if field.Max != nil && number > *field.Max {
+			return fmt.Sprintf("%v is greater than maximum %v", number, *field.Max)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return "expected string"
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "expected boolean"
+		}
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return "expected object"
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return "expected array"
+		}
+	}
+	if len(field.Enum) > 0 {
+		for _, allowed := range field.Enum {
+			if reflect.DeepEqual(allowed, value) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%v is not one of %v", value, field.Enum)
+	}
+	return ""
+}
+
+// schemaUnits device_type 对应schema中声明的字段单位（调用方需持有锁）
+func (m *Manager) schemaUnits(deviceType string) map[string]string {
+	schema, _ := m.schemaFor(deviceType)
+	if schema == nil {
+		return nil
+	}
+	var units map[string]string
+	for name, field := range schema.Fields {
+		if field.Unit == "" {
+			continue
+		}
+		if units == nil {
+			units = make(map[string]string)
+		}
+		units[name] = field.Unit
+	}
+	return units
+}
@@ -0,0 +1,63 @@
+package appmanager
+
+import (
+	"io"
+	"testing"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidateReportDeviceType(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	thermostat := &models.DeviceSchema{Fields: map[string]models.FieldSchema{"room_temp": {Type: "number", Required: true}}}
+	valid := map[string]interface{}{"room_temp": 21.5}
+
+	tests := []struct {
+		name       string
+		manifest   *manifest
+		deviceType string
+		data       map[string]interface{}
+		reject     bool
+	}{
+		{"no schemas declared", &manifest{AppName: "thermostat"}, "anything", map[string]interface{}{"x": 1.0}, false},
+		{"matching device type", &manifest{AppName: "app", Schemas: map[string]*models.DeviceSchema{"thermostat": thermostat}}, "thermostat", valid, false},
+		{"invalid data", &manifest{AppName: "app", Schemas: map[string]*models.DeviceSchema{"thermostat": thermostat}}, "thermostat", map[string]interface{}{}, true},
+		{"missing device type uses app name", &manifest{AppName: "thermostat", Schemas: map[string]*models.DeviceSchema{"thermostat": thermostat}}, "", map[string]interface{}{}, true},
+		{"missing device type with valid data", &manifest{AppName: "thermostat", Schemas: map[string]*models.DeviceSchema{"thermostat": thermostat}}, "", valid, false},
+		{"missing device type without app name schema", &manifest{AppName: "app", Schemas: map[string]*models.DeviceSchema{"thermostat": thermostat}}, "", valid, true},
+		{"unknown device type", &manifest{AppName: "thermostat", Schemas: map[string]*models.DeviceSchema{"thermostat": thermostat}}, "cleaner", valid, true},
+	}
+	for _, tt := range tests {
+		m := &Manager{logger: logger}
+		m.loadSchemas(tt.manifest)
+		_, err := m.validateReport(tt.deviceType, tt.data)
+		if tt.reject != (err != nil) {
+			t.Errorf("%s: got error %v, want reject %v", tt.name, err, tt.reject)
+		}
+	}
+}
+
+func TestCheckSchemas(t *testing.T) {
+	min, max := 10.0, 0.0
+	tests := []struct {
+		name    string
+		schemas map[string]*models.DeviceSchema
+		valid   bool
+	}{
+		{"valid", map[string]*models.DeviceSchema{"thermostat": {Validation: models.SchemaValidationFlag, Fields: map[string]models.FieldSchema{"mode": {Type: "string"}}}}, true},
+		{"empty device type", map[string]*models.DeviceSchema{"": {}}, false},
+		{"null schema", map[string]*models.DeviceSchema{"thermostat": nil}, false},
+		{"unknown validation", map[string]*models.DeviceSchema{"thermostat": {Validation: "warn"}}, false},
+		{"unknown type", map[string]*models.DeviceSchema{"thermostat": {Fields: map[string]models.FieldSchema{"mode": {Type: "text"}}}}, false},
+		{"range on string", map[string]*models.DeviceSchema{"thermostat": {Fields: map[string]models.FieldSchema{"mode": {Type: "string", Min: &min}}}}, false},
+		{"min greater than max", map[string]*models.DeviceSchema{"thermostat": {Fields: map[string]models.FieldSchema{"temp": {Type: "number", Min: &min, Max: &max}}}}, false},
+	}
+	for _, tt := range tests {
+		if err := checkSchemas(tt.schemas); tt.valid != (err == nil) {
+			t.Errorf("%s: got error %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package appmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"brick-smart-template/pkg/models"
+)
+
+// statsBuckets 每个统计窗口划分的桶数，窗口边缘的精度为窗口长度的 1/statsBuckets
+const statsBuckets = 60
+
+// DefaultStatsWindows 默认的统计窗口
+var DefaultStatsWindows = []string{"1m", "15m", "1h"}
+
+// statsWindow 统计窗口
+type statsWindow struct {
+	name  string // 配置中的写法，如 "15m"
+	width time.Duration
+}
+
+// fieldStats 一个数值字段的增量统计，只保存各窗口的分桶汇总，不保存原始样本
+type fieldStats struct {
+	last       float64
+	lastChange time.Time
+	lastSeen   time.Time
+	rings      []statsRing // 与 Manager.statsWindows 一一对应
+}
+
+// statsRing 一个窗口的环形分桶
+type statsRing struct {
+	bucketWidth int64 // 纳秒
+	buckets     [statsBuckets]statsBucket
+}
+
+// statsBucket 一个桶内样本的汇总（Welford 算法的 count/mean/M2，合并时不损失精度）
+type statsBucket struct {
+	index int64 // 时间除以桶宽度得到的序号
+	count int
+	mean  float64
+	m2    float64
+	min   float64
+	max   float64
+}
+
+// SetStatsWindows 设置数值字段滚动统计的窗口（如 "1m"、"15m"），会清空已有的统计
+func (m *Manager) SetStatsWindows(names []string) error {
+	windows, err := parseStatsWindows(names)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.statsWindows = windows
+	m.stats = make(map[string]*fieldStats)
+	return nil
+}
+
+// parseStatsWindows 解析统计窗口，窗口至少为 statsBuckets 毫秒，重复的窗口只保留一个
+func parseStatsWindows(names []string) ([]statsWindow, error) {
+	windows := make([]statsWindow, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		width, err := time.ParseDuration(name)
+		if err != nil || width < statsBuckets*time.Millisecond {
+			return nil, fmt.Errorf("invalid stats window %q", name)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		windows = append(windows, statsWindow{name: name, width: width})
+	}
+	return windows, nil
+}
+
+// recordStats 用一次内部状态更新数值字段的统计，并丢弃超过最长窗口未出现的字段（调用方需持有锁）
+func (m *Manager) recordStats(t time.Time, data map[string]interface{}) {
+	if len(m.statsWindows) == 0 {
+		return
+	}
+	walkNumbers("", data, func(field string, value float64) {
+		stats, ok := m.stats[field]
+		if !ok {
+			stats = &fieldStats{last: value, lastChange: t, rings: make([]statsRing, len(m.statsWindows))}
+			for i, window := range m.statsWindows {
+				stats.rings[i].bucketWidth = int64(window.width / statsBuckets)
+			}
+			m.stats[field] = stats
+		}
+		if value != stats.last {
+			stats.last = value
+			stats.lastChange = t
+		}
+		stats.lastSeen = t
+		for i := range stats.rings {
+			stats.rings[i].add(t.UnixNano(), value)
+		}
+	})
+
+	longest := time.Duration(0)
+	for _, window := range m.statsWindows {
+		if window.width > longest {
+			longest = window.width
+		}
+	}
+	for field, stats := range m.stats {
+		if t.Sub(stats.lastSeen) > longest {
+			delete(m.stats, field)
+		}
+	}
+}
+
+// walkNumbers 遍历数值字段，嵌套对象的字段名以 "." 连接
+func walkNumbers(prefix string, data map[string]interface{}, fn func(field string, value float64)) {
+	for key, value := range data {
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			walkNumbers(field, v, fn)
+		case float64:
+			fn(field, v)
+		case int:
+			fn(field, float64(v))
+		case int64:
+			fn(field, float64(v))
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				fn(field, f)
+			}
+		}
+	}
+}
+
+// add 把样本加入所在的桶，桶已过期时先清空
+func (r *statsRing) add(ts int64, value float64) {
+	index := ts / r.bucketWidth
+	bucket := &r.buckets[index%statsBuckets]
+	if bucket.index != index || bucket.count == 0 {
+		*bucket = statsBucket{index: index, min: value, max: value}
+	}
+	bucket.count++
+	delta := value - bucket.mean
+	bucket.mean += delta / float64(bucket.count)
+	bucket.m2 += delta * (value - bucket.mean)
+	bucket.min = math.Min(bucket.min, value)
+	bucket.max = math.Max(bucket.max, value)
+}
+
+// window 合并窗口内的桶，窗口内没有样本时返回false
+func (r *statsRing) window(now int64) (models.WindowStats, bool) {
+	current := now / r.bucketWidth
+	var total statsBucket
+	for i := range r.buckets {
+		bucket := &r.buckets[i]
+		if bucket.count == 0 || bucket.index <= current-statsBuckets || bucket.index > current {
+			continue
+		}
+		if total.count == 0 {
+			total = *bucket
+			continue
+		}
+		// 并行合并两组样本的均值和M2
+		count := total.count + bucket.count
+		delta := bucket.mean - total.mean
+		total.m2 += bucket.m2 + delta*delta*float64(total.count)*float64(bucket.count)/float64(count)
+		total.mean += delta * float64(bucket.count) / float64(count)
+		total.count = count
+		total.min = math.Min(total.min, bucket.min)
+		total.max = math.Max(total.max, bucket.max)
+	}
+	if total.count == 0 {
+		return models.WindowStats{}, false
+	}
+	return models.WindowStats{
+		Count:  total.count,
+		Min:    total.min,
+		Max:    total.max,
+		Avg:    total.mean,
+		StdDev: math.Sqrt(total.m2 / float64(total.count)),
+	}, true
+}
+
+// DataStats 获取数值字段在各窗口内的统计，field 不为空时只返回该字段
+func (m *Manager) DataStats(field string) models.DataStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := models.DataStats{Windows: make([]string, 0, len(m.statsWindows)), Fields: make(map[string]models.FieldStats)}
+	for _, window := range m.statsWindows {
+		result.Windows = append(result.Windows, window.name)
+	}
+	now := time.Now().UnixNano()
+	for name, stats := range m.stats {
+		if field != "" && name != field {
+			continue
+		}
+		view := models.FieldStats{Last: stats.last, LastChange: stats.lastChange, Windows: make(map[string]models.WindowStats)}
+		for i, window := range m.statsWindows {
+			if windowStats, ok := stats.rings[i].window(now); ok {
+				view.Windows[window.name] = windowStats
+			}
+		}
+		result.Fields[name] = view
+	}
+	return result
+}
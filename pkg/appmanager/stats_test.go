@@ -0,0 +1,156 @@
+package appmanager
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// naiveStats 直接计算样本的均值和总体标准差
+func naiveStats(values []float64) (mean, stddev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		stddev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(values)))
+}
+
+func TestStatsRingMerge(t *testing.T) {
+	const width = int64(time.Second)
+	tests := []struct {
+		name   string
+		values []float64
+		gap    int64 // 相邻样本的间隔
+	}{
+		{"single sample", []float64{42}, width},
+		{"one bucket", []float64{1, 2, 3, 4}, 0},
+		{"one sample per bucket", []float64{10, 20, 30, 40, 50}, width},
+		{"uneven buckets", []float64{1, 1, 1, 9, 2, 8, 3, 7, 4}, width / 3},
+		{"constant", []float64{5, 5, 5, 5, 5, 5}, width / 2},
+		{"large offset", []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}, width},
+		{"negative", []float64{-3, 0, 3, -1.5, 1.5}, width * 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := statsRing{bucketWidth: width}
+			ts := 1000 * width
+			for _, v := range tt.values {
+				ring.add(ts, v)
+				ts += tt.gap
+			}
+			got, ok := ring.window(ts)
+			if !ok {
+				t.Fatal("window is empty")
+			}
+			mean, stddev := naiveStats(tt.values)
+			min, max := math.Inf(1), math.Inf(-1)
+			for _, v := range tt.values {
+				min, max = math.Min(min, v), math.Max(max, v)
+			}
+			if got.Count != len(tt.values) || got.Min != min || got.Max != max {
+				t.Errorf("count/min/max = %d/%v/%v, want %d/%v/%v", got.Count, got.Min, got.Max, len(tt.values), min, max)
+			}
+			if math.Abs(got.Avg-mean) > 1e-9*math.Max(1, math.Abs(mean)) {
+				t.Errorf("avg = %v, want %v", got.Avg, mean)
+			}
+			if math.Abs(got.StdDev-stddev) > 1e-6 {
+				t.Errorf("stddev = %v, want %v", got.StdDev, stddev)
+			}
+		})
+	}
+}
+
+func TestStatsRingExpiry(t *testing.T) {
+	const width = int64(time.Second)
+	ring := statsRing{bucketWidth: width}
+	start := 1000 * width
+	ring.add(start, 100)
+	ring.add(start+10*width, 1)
+
+	// 两个样本都在窗口内
+	if got, _ := ring.window(start + 10*width); got.Count != 2 {
+		t.Errorf("count = %d, want 2", got.Count)
+	}
+	// 第一个样本所在的桶移出窗口
+	got, _ := ring.window(start + statsBuckets*width)
+	if got.Count != 1 || got.Max != 1 {
+		t.Errorf("after expiry: count = %d, max = %v, want 1 and 1", got.Count, got.Max)
+	}
+	// 所有桶都过期
+	if _, ok := ring.window(start + (statsBuckets+10)*width); ok {
+		t.Errorf("window should be empty")
+	}
+
+	// 环绕后复用同一位置的桶时清空旧的汇总
+	ring.add(start+statsBuckets*width, 7)
+	got, _ = ring.window(start + statsBuckets*width)
+	if got.Count != 2 || got.Min != 1 || got.Max != 7 {
+		t.Errorf("after wrap: count = %d, min = %v, max = %v, want 2, 1, 7", got.Count, got.Min, got.Max)
+	}
+}
+
+func TestParseStatsWindows(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  int // -1 表示期望失败
+	}{
+		{[]string{"1m", "15m", "1h"}, 3},
+		{[]string{"1m", "1m"}, 1},
+		{[]string{}, 0},
+		{[]string{"60ms"}, 1},
+		{[]string{"59ms"}, -1},
+		{[]string{"-1m"}, -1},
+		{[]string{"soon"}, -1},
+	}
+	for _, tt := range tests {
+		windows, err := parseStatsWindows(tt.names)
+		if tt.want < 0 {
+			if err == nil {
+				t.Errorf("%v: expected error", tt.names)
+			}
+			continue
+		}
+		if err != nil || len(windows) != tt.want {
+			t.Errorf("%v: got %d windows, err %v, want %d", tt.names, len(windows), err, tt.want)
+		}
+	}
+}
+
+func TestRecordStats(t *testing.T) {
+	m := &Manager{}
+	windows, err := parseStatsWindows([]string{"1m", "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.statsWindows, m.stats = windows, make(map[string]*fieldStats)
+
+	base := time.Now()
+	m.recordStats(base, map[string]interface{}{"battery": 80.0, "sensor": map[string]interface{}{"temp": 21}, "mode": "eco"})
+	m.recordStats(base.Add(time.Second), map[string]interface{}{"battery": 80.0, "sensor": map[string]interface{}{"temp": 22}})
+
+	if _, ok := m.stats["mode"]; ok {
+		t.Errorf("string field should not have stats")
+	}
+	temp, ok := m.stats["sensor.temp"]
+	if !ok {
+		t.Fatal("nested field sensor.temp has no stats")
+	}
+	if temp.last != 22 || !temp.lastChange.Equal(base.Add(time.Second)) {
+		t.Errorf("sensor.temp last = %v at %v", temp.last, temp.lastChange)
+	}
+	if battery := m.stats["battery"]; !battery.lastChange.Equal(base) {
+		t.Errorf("unchanged value should keep last_change, got %v", battery.lastChange)
+	}
+
+	// 超过最长窗口没有出现的字段被移除
+	m.recordStats(base.Add(2*time.Hour), map[string]interface{}{"battery": 70.0})
+	if _, ok := m.stats["sensor.temp"]; ok {
+		t.Errorf("stale field was not removed")
+	}
+	if got, _ := m.stats["battery"].rings[1].window(base.Add(2 * time.Hour).UnixNano()); got.Count != 1 || got.Avg != 70 {
+		t.Errorf("battery 1h window = %+v, want only the latest sample", got)
+	}
+}
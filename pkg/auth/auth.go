@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ErrNoCredentials 请求中没有该认证方式的凭证
+var ErrNoCredentials = errors.New("no credentials")
+
+// 认证方式
+const (
+	MethodBearer   = "bearer"
+	MethodHMAC     = "hmac"
+	MethodMTLS     = "mtls"
+	MethodAppToken = "app_token"
+	MethodPeerCred = "peer_cred"
+)
+
+// Identity 认证后的调用方身份
+type Identity struct {
+	Subject string `json:"subject"`
+	Method  string `json:"method"`
+	Role    string `json:"role"`
+}
+
+// Authenticator 认证器
+// 请求不含该方式的凭证时返回 ErrNoCredentials，凭证无效时返回其他错误
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Chain 依次尝试多个认证器，第一个识别出凭证的认证器决定结果
+type Chain []Authenticator
+
+// Authenticate 实现 Authenticator
+func (chain Chain) Authenticate(r *http.Request) (*Identity, error) {
+	for _, authenticator := range chain {
+		identity, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return identity, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// TokenConfig 静态bearer token
+type TokenConfig struct {
+	Name  string `mapstructure:"name"`
+	Token string `mapstructure:"token"`
+}
+
+// HMACKeyConfig HMAC签名密钥
+type HMACKeyConfig struct {
+	ID     string `mapstructure:"id"`
+	Secret string `mapstructure:"secret"`
+}
+
+// Config 配置文件中的 auth 段
+type Config struct {
+	Tokens []TokenConfig `mapstructure:"tokens"`
+	HMAC   struct {
+		MaxSkew      time.Duration   `mapstructure:"max_skew"`
+		MaxBodyBytes int64           `mapstructure:"max_body_bytes"` // 签名请求体的上限，默认 1MiB
+		Keys         []HMACKeyConfig `mapstructure:"keys"`
+	} `mapstructure:"hmac"`
+	MTLS struct {
+		Enabled         bool     `mapstructure:"enabled"`
+		AllowedSubjects []string `mapstructure:"allowed_subjects"`
+	} `mapstructure:"mtls"`
+	Roles       []RoleBinding `mapstructure:"roles"`
+	DefaultRole string        `mapstructure:"default_role"` // 未绑定角色的调用方使用的角色，默认 viewer，"none" 表示无权限
+}
+
+// Load 从viper配置加载认证器，未配置任何认证方式时返回nil（不启用认证）
+func Load() (Authenticator, error) {
+	var config Config
+	if err := viper.UnmarshalKey("auth", &config); err != nil {
+		return nil, fmt.Errorf("invalid auth config: %v", err)
+	}
+	return New(config)
+}
+
+// New 根据配置创建认证器
+func New(config Config) (Authenticator, error) {
+	var chain Chain
+	if len(config.Tokens) > 0 {
+		bearer, err := NewBearerAuthenticator(config.Tokens)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, bearer)
+	}
+	if len(config.HMAC.Keys) > 0 {
+		hmacAuth, err := NewHMACAuthenticator(config.HMAC.Keys, config.HMAC.MaxSkew, config.HMAC.MaxBodyBytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, hmacAuth)
+	}
+	if config.MTLS.Enabled {
+		chain = append(chain, NewCertAuthenticator(config.MTLS.AllowedSubjects))
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+
+	mapper, err := newRoleMapper(chain, config.Roles, config.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+	return mapper, nil
+}
+
+// BearerToken 从 Authorization 头中取出bearer token
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
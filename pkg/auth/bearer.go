@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+)
+
+// BearerAuthenticator 静态bearer token认证
+type BearerAuthenticator struct {
+	tokens []TokenConfig
+}
+
+// NewBearerAuthenticator 创建bearer token认证器
+func NewBearerAuthenticator(tokens []TokenConfig) (*BearerAuthenticator, error) {
+	for _, token := range tokens {
+		if token.Name == "" || token.Token == "" {
+			return nil, fmt.Errorf("auth token requires name and token")
+		}
+	}
+	return &BearerAuthenticator{tokens: tokens}, nil
+}
+
+// Authenticate 实现 Authenticator
+func (a *BearerAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token := BearerToken(r)
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	for _, candidate := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate.Token)) == 1 {
+			return &Identity{Subject: candidate.Name, Method: MethodBearer}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid bearer token")
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HMAC签名请求头
+const (
+	HeaderKeyID     = "X-Auth-Key"
+	HeaderTimestamp = "X-Auth-Timestamp"
+	HeaderNonce     = "X-Auth-Nonce"
+	HeaderSignature = "X-Auth-Signature"
+)
+
+// defaultMaxSkew 默认允许的时间偏差
+const defaultMaxSkew = 5 * time.Minute
+
+// defaultMaxBodyBytes 默认允许签名的最大请求体
+const defaultMaxBodyBytes = 1 << 20
+
+// HMACAuthenticator HMAC签名请求认证
+//
+// 签名内容为以下字段以换行拼接：
+//
+//	METHOD
+//	PATH?QUERY
+//	TIMESTAMP（unix秒）
+//	NONCE
+//	hex(sha256(BODY))
+//
+// X-Auth-Signature 为 hex(HMAC-SHA256(secret, 签名内容))。
+// 时间戳超出允许偏差或nonce在有效期内重复使用的请求会被拒绝。
+type HMACAuthenticator struct {
+	keys         map[string][]byte
+	maxSkew      time.Duration
+	maxBodyBytes int64
+
+	mu     sync.Mutex
+	nonces map[string]time.Time // nonce -> 过期时间
+}
+
+// NewHMACAuthenticator 创建HMAC签名认证器
+func NewHMACAuthenticator(keys []HMACKeyConfig, maxSkew time.Duration, maxBodyBytes int64) (*HMACAuthenticator, error) {
+	if maxSkew <= 0 {
+		maxSkew = defaultMaxSkew
+	}
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	a := &HMACAuthenticator{
+		keys:         make(map[string][]byte),
+		maxSkew:      maxSkew,
+		maxBodyBytes: maxBodyBytes,
+		nonces:       make(map[string]time.Time),
+	}
+	for _, key := range keys {
+		if key.ID == "" || key.Secret == "" {
+			return nil, fmt.Errorf("auth hmac key requires id and secret")
+		}
+		a.keys[key.ID] = []byte(key.Secret)
+	}
+	return a, nil
+}
+
+// Authenticate 实现 Authenticator
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	keyID := r.Header.Get(HeaderKeyID)
+	if keyID == "" {
+		return nil, ErrNoCredentials
+	}
+	secret, ok := a.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown hmac key %q", keyID)
+	}
+
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	signature := r.Header.Get(HeaderSignature)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return nil, fmt.Errorf("missing hmac signature headers")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hmac timestamp")
+	}
+	now := time.Now()
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > a.maxSkew || skew < -a.maxSkew {
+		return nil, fmt.Errorf("hmac timestamp outside allowed window")
+	}
+
+	// 读取请求体计算摘要后放回，供后续handler使用；签名校验前限制读取的大小
+	var body []byte
+	if r.Body != nil {
+		if r.ContentLength > a.maxBodyBytes {
+			return nil, fmt.Errorf("request body exceeds %d bytes", a.maxBodyBytes)
+		}
+		body, err = io.ReadAll(http.MaxBytesReader(nil, r.Body, a.maxBodyBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := Sign(secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	provided, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(provided, expected) {
+		return nil, fmt.Errorf("invalid hmac signature")
+	}
+
+	// 签名有效后再登记nonce，防止伪造请求占用nonce
+	if !a.useNonce(keyID+":"+nonce, now) {
+		return nil, fmt.Errorf("hmac nonce already used")
+	}
+	return &Identity{Subject: keyID, Method: MethodHMAC}, nil
+}
+
+// useNonce 登记nonce，重复使用时返回false
+func (a *HMACAuthenticator) useNonce(nonce string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, expiry := range a.nonces {
+		if now.After(expiry) {
+			delete(a.nonces, key)
+		}
+	}
+	if _, used := a.nonces[nonce]; used {
+		return false
+	}
+	// 时间窗口前后各 maxSkew 内的请求都可能被重放
+	a.nonces[nonce] = now.Add(2 * a.maxSkew)
+	return true
+}
+
+// Sign 计算HMAC请求签名
+func Sign(secret []byte, method, requestURI, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, requestURI, timestamp, nonce, hex.EncodeToString(bodyHash[:]))
+	return mac.Sum(nil)
+}
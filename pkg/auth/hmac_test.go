@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "change-me-too"
+
+// signedRequest 构造带HMAC签名请求头的请求
+func signedRequest(method, target, body string, timestamp time.Time, nonce string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	r.Header.Set(HeaderKeyID, "orchestrator")
+	r.Header.Set(HeaderTimestamp, ts)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, hex.EncodeToString(Sign([]byte(testSecret), method, r.URL.RequestURI(), ts, nonce, []byte(body))))
+	return r
+}
+
+// newTestHMAC 创建测试用的HMAC认证器
+func newTestHMAC(t *testing.T, maxBodyBytes int64) *HMACAuthenticator {
+	t.Helper()
+	a, err := NewHMACAuthenticator([]HMACKeyConfig{{ID: "orchestrator", Secret: testSecret}}, time.Minute, maxBodyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestHMACAuthenticate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		ok      bool
+	}{
+		{"valid", func() *http.Request {
+			return signedRequest("POST", "/v1/app/restart?wait=true", `{"profile":"{}"}`, now, "n1")
+		}, true},
+		{"method changed", func() *http.Request {
+			r := signedRequest("POST", "/v1/app/stop", "", now, "n2")
+			r.Method = "DELETE"
+			return r
+		}, false},
+		{"path changed", func() *http.Request {
+			r := signedRequest("POST", "/v1/app/stop", "", now, "n3")
+			r.URL.Path = "/v1/app/configure"
+			return r
+		}, false},
+		{"query changed", func() *http.Request {
+			r := signedRequest("POST", "/v1/app/stop?wait=true", "", now, "n4")
+			r.URL.RawQuery = "wait=false"
+			return r
+		}, false},
+		{"body changed", func() *http.Request {
+			r := signedRequest("POST", "/v1/app/configure", `{"command":"/app/cleaner"}`, now, "n5")
+			r.Body = io.NopCloser(strings.NewReader(`{"command":"/bin/sh"}`))
+			return r
+		}, false},
+		{"timestamp too old", func() *http.Request {
+			return signedRequest("POST", "/v1/app/stop", "", now.Add(-2*time.Minute), "n6")
+		}, false},
+		{"timestamp in the future", func() *http.Request {
+			return signedRequest("POST", "/v1/app/stop", "", now.Add(2*time.Minute), "n7")
+		}, false},
+		{"timestamp within skew", func() *http.Request {
+			return signedRequest("POST", "/v1/app/stop", "", now.Add(-30*time.Second), "n8")
+		}, true},
+		{"invalid timestamp", func() *http.Request {
+			r := signedRequest("POST", "/v1/app/stop", "", now, "n9")
+			r.Header.Set(HeaderTimestamp, "yesterday")
+			return r
+		}, false},
+		{"unknown key", func() *http.Request {
+			r := signedRequest("POST", "/v1/app/stop", "", now, "n10")
+			r.Header.Set(HeaderKeyID, "someone")
+			return r
+		}, false},
+		{"missing nonce", func() *http.Request {
+			r := signedRequest("POST", "/v1/app/stop", "", now, "n11")
+			r.Header.Del(HeaderNonce)
+			return r
+		}, false},
+		{"malformed signature", func() *http.Request {
+			r := signedRequest("POST", "/v1/app/stop", "", now, "n12")
+			r.Header.Set(HeaderSignature, "zz")
+			return r
+		}, false},
+	}
+	a := newTestHMAC(t, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := a.Authenticate(tt.request())
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if identity.Subject != "orchestrator" || identity.Method != MethodHMAC {
+					t.Fatalf("identity = %+v", identity)
+				}
+				return
+			}
+			if err == nil || errors.Is(err, ErrNoCredentials) {
+				t.Fatalf("got %v, want authentication failure", err)
+			}
+		})
+	}
+
+	if _, err := a.Authenticate(httptest.NewRequest("GET", "/v1/app/status", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("request without key: got %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestHMACBodyIsRestored(t *testing.T) {
+	body := `{"profile":"{}"}`
+	r := signedRequest("POST", "/v1/app/start", body, time.Now(), "n1")
+	if _, err := newTestHMAC(t, 0).Authenticate(r); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(restored) != body {
+		t.Errorf("body after authentication = %q, want %q", restored, body)
+	}
+}
+
+func TestHMACNonceReplay(t *testing.T) {
+	a := newTestHMAC(t, 0)
+	now := time.Now()
+	if _, err := a.Authenticate(signedRequest("POST", "/v1/app/stop", "", now, "once")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate(signedRequest("POST", "/v1/app/stop", "", now, "once")); err == nil {
+		t.Fatalf("replayed nonce was accepted")
+	}
+
+	// 签名无效的请求不会占用nonce
+	forged := signedRequest("POST", "/v1/app/stop", "", now, "fresh")
+	forged.Header.Set(HeaderSignature, hex.EncodeToString([]byte("forged")))
+	if _, err := a.Authenticate(forged); err == nil {
+		t.Fatalf("forged signature was accepted")
+	}
+	if _, err := a.Authenticate(signedRequest("POST", "/v1/app/stop", "", now, "fresh")); err != nil {
+		t.Fatalf("nonce used by a forged request: %v", err)
+	}
+
+	// 过期的nonce被清理
+	if !a.useNonce("orchestrator:late", now) {
+		t.Fatal("useNonce rejected a new nonce")
+	}
+	if a.useNonce("orchestrator:late", now.Add(time.Minute)) {
+		t.Errorf("nonce reused within its window")
+	}
+	if !a.useNonce("orchestrator:late", now.Add(3*time.Minute)) {
+		t.Errorf("expired nonce was not released")
+	}
+}
+
+func TestHMACBodyLimit(t *testing.T) {
+	a := newTestHMAC(t, 16)
+	now := time.Now()
+	if _, err := a.Authenticate(signedRequest("POST", "/v1/app/configure", strings.Repeat("a", 16), now, "n1")); err != nil {
+		t.Fatalf("body at the limit: %v", err)
+	}
+	if _, err := a.Authenticate(signedRequest("POST", "/v1/app/configure", strings.Repeat("a", 17), now, "n2")); err == nil {
+		t.Errorf("oversized body was accepted")
+	}
+
+	// 未声明长度的请求体按实际读取的大小限制
+	r := signedRequest("POST", "/v1/app/configure", strings.Repeat("a", 17), now, "n3")
+	r.ContentLength = -1
+	if _, err := a.Authenticate(r); err == nil {
+		t.Errorf("oversized chunked body was accepted")
+	}
+}
+
+func TestNewHMACAuthenticatorValidation(t *testing.T) {
+	for _, keys := range [][]HMACKeyConfig{{{ID: "a"}}, {{Secret: "s"}}} {
+		if _, err := NewHMACAuthenticator(keys, 0, 0); err == nil {
+			t.Errorf("NewHMACAuthenticator(%+v): expected error", keys)
+		}
+	}
+}
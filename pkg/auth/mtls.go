@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// CertAuthenticator mTLS客户端证书认证
+// 证书链由TLS握手按配置的client CA校验，这里只按证书CN做准入
+type CertAuthenticator struct {
+	allowed map[string]bool
+}
+
+// NewCertAuthenticator 创建客户端证书认证器，allowedSubjects为空时接受所有已验证的证书
+func NewCertAuthenticator(allowedSubjects []string) *CertAuthenticator {
+	allowed := make(map[string]bool)
+	for _, subject := range allowedSubjects {
+		allowed[subject] = true
+	}
+	return &CertAuthenticator{allowed: allowed}
+}
+
+// Authenticate 实现 Authenticator
+func (a *CertAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if len(a.allowed) > 0 && !a.allowed[subject] {
+		return nil, fmt.Errorf("client certificate %q is not allowed", subject)
+	}
+	return &Identity{Subject: subject, Method: MethodMTLS}, nil
+}
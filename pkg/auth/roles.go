@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// 角色
+const (
+	RoleViewer   = "viewer"   // 只读：状态和数据
+	RoleOperator = "operator" // 启停控制
+	RoleAdmin    = "admin"    // 配置应用
+	RoleDevice   = "device"   // 设备上报，仅限状态上报接口
+
+	// roleNone 作为 default_role 时未绑定的调用方没有任何权限
+	roleNone = "none"
+)
+
+// roleLevels 管理角色的权限等级，高等级包含低等级的权限
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// bindableMethods 可以绑定角色的认证方式
+var bindableMethods = map[string]bool{MethodBearer: true, MethodHMAC: true, MethodMTLS: true}
+
+// RoleBinding 调用方到角色的绑定
+// 绑定同时匹配认证方式和 subject，避免证书 CN 与 token 名或 HMAC key id 相同时冒用其角色
+type RoleBinding struct {
+	Method  string `mapstructure:"method"` // bearer、hmac 或 mtls
+	Subject string `mapstructure:"subject"`
+	Role    string `mapstructure:"role"`
+}
+
+// bindingKey 角色绑定的索引
+type bindingKey struct {
+	method  string
+	subject string
+}
+
+// ValidRole 判断角色名是否有效
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok || role == RoleDevice
+}
+
+// Allows 判断角色是否满足要求的角色
+// device角色只匹配device，管理角色按等级包含
+func Allows(role, required string) bool {
+	if role == RoleDevice || required == RoleDevice {
+		return role == required
+	}
+	level, ok := roleLevels[role]
+	return ok && level >= roleLevels[required]
+}
+
+// roleMapper 为认证结果绑定角色
+type roleMapper struct {
+	inner       Authenticator
+	roles       map[bindingKey]string
+	defaultRole string
+}
+
+// newRoleMapper 创建角色绑定，未绑定的调用方使用 defaultRole
+// defaultRole 为空时为 viewer，为 "none" 时未绑定的调用方没有任何权限
+func newRoleMapper(inner Authenticator, bindings []RoleBinding, defaultRole string) (*roleMapper, error) {
+	switch defaultRole {
+	case "":
+		defaultRole = RoleViewer
+	case roleNone:
+		defaultRole = ""
+	default:
+		if !ValidRole(defaultRole) {
+			return nil, fmt.Errorf("invalid auth default_role %q", defaultRole)
+		}
+	}
+	mapper := &roleMapper{
+		inner:       inner,
+		roles:       make(map[bindingKey]string),
+		defaultRole: defaultRole,
+	}
+	for _, binding := range bindings {
+		if !bindableMethods[binding.Method] {
+			return nil, fmt.Errorf("invalid method %q for subject %q: must be bearer, hmac or mtls", binding.Method, binding.Subject)
+		}
+		if !ValidRole(binding.Role) {
+			return nil, fmt.Errorf("invalid role %q for subject %q", binding.Role, binding.Subject)
+		}
+		mapper.roles[bindingKey{method: binding.Method, subject: binding.Subject}] = binding.Role
+	}
+	return mapper, nil
+}
+
+// Authenticate 实现 Authenticator
+func (m *roleMapper) Authenticate(r *http.Request) (*Identity, error) {
+	identity, err := m.inner.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	identity.Role = m.defaultRole
+	if role, ok := m.roles[bindingKey{method: identity.Method, subject: identity.Subject}]; ok {
+		identity.Role = role
+	}
+	return identity, nil
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// staticAuthenticator 总是返回给定身份的认证器
+type staticAuthenticator Identity
+
+// Authenticate 实现 Authenticator
+func (a staticAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	identity := Identity(a)
+	return &identity, nil
+}
+
+func TestRoleBindings(t *testing.T) {
+	bindings := []RoleBinding{
+		{Method: MethodMTLS, Subject: "orchestrator", Role: RoleAdmin},
+		{Method: MethodBearer, Subject: "dashboard", Role: RoleOperator},
+	}
+	tests := []struct {
+		name        string
+		identity    Identity
+		defaultRole string
+		want        string
+	}{
+		{"bound certificate", Identity{Subject: "orchestrator", Method: MethodMTLS}, "", RoleAdmin},
+		{"same subject other method", Identity{Subject: "orchestrator", Method: MethodHMAC}, "", RoleViewer},
+		{"bound token", Identity{Subject: "dashboard", Method: MethodBearer}, "", RoleOperator},
+		{"token name as certificate", Identity{Subject: "dashboard", Method: MethodMTLS}, "", RoleViewer},
+		{"unbound defaults to viewer", Identity{Subject: "someone", Method: MethodBearer}, "", RoleViewer},
+		{"explicit default role", Identity{Subject: "someone", Method: MethodBearer}, RoleOperator, RoleOperator},
+		{"default role none", Identity{Subject: "someone", Method: MethodHMAC}, "none", ""},
+		{"bound with default none", Identity{Subject: "orchestrator", Method: MethodMTLS}, "none", RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := newRoleMapper(staticAuthenticator(tt.identity), bindings, tt.defaultRole)
+			if err != nil {
+				t.Fatal(err)
+			}
+			identity, err := mapper.Authenticate(httptest.NewRequest("GET", "/v1/app/status", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.Role != tt.want {
+				t.Fatalf("role = %q, want %q", identity.Role, tt.want)
+			}
+			// default_role 为 none 时未绑定的调用方连只读权限也没有
+			if tt.want == "" && Allows(identity.Role, RoleViewer) {
+				t.Fatalf("role %q allows viewer", identity.Role)
+			}
+		})
+	}
+}
+
+func TestRoleBindingsFromConfig(t *testing.T) {
+	var config Config
+	config.Tokens = []TokenConfig{{Name: "dashboard", Token: "t1"}, {Name: "orchestrator", Token: "t2"}}
+	config.Roles = []RoleBinding{{Method: MethodMTLS, Subject: "orchestrator", Role: RoleAdmin}}
+	config.DefaultRole = "none"
+	authenticator, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 与证书 CN 同名的 token 不会获得证书的角色
+	r := httptest.NewRequest("POST", "/v1/app/configure", nil)
+	r.Header.Set("Authorization", "Bearer t2")
+	identity, err := authenticator.Authenticate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Method != MethodBearer || identity.Role != "" {
+		t.Errorf("identity = %+v, want bearer without role", identity)
+	}
+}
+
+func TestRoleBindingValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		bindings    []RoleBinding
+		defaultRole string
+	}{
+		{"missing method", []RoleBinding{{Subject: "a", Role: RoleAdmin}}, ""},
+		{"app token method", []RoleBinding{{Method: MethodAppToken, Subject: "a", Role: RoleAdmin}}, ""},
+		{"unknown role", []RoleBinding{{Method: MethodBearer, Subject: "a", Role: "root"}}, ""},
+		{"unknown default role", nil, "root"},
+	}
+	for _, tt := range tests {
+		if _, err := newRoleMapper(staticAuthenticator{}, tt.bindings, tt.defaultRole); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		role, required string
+		want           bool
+	}{
+		{RoleAdmin, RoleOperator, true},
+		{RoleOperator, RoleAdmin, false},
+		{RoleViewer, RoleViewer, true},
+		{RoleDevice, RoleDevice, true},
+		{RoleAdmin, RoleDevice, false},
+		{RoleDevice, RoleViewer, false},
+		{"", RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.role, tt.required); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
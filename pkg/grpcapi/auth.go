@@ -0,0 +1,103 @@
+package grpcapi
+
+import (
+	"context"
+
+	"brick-smart-template/pkg/auth"
+	"brick-smart-template/pkg/grpcapi/proxypb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// methodRoles 各RPC要求的角色，与HTTP API的路由分组一致
+var methodRoles = map[string]string{
+	proxypb.ProxyService_Configure_FullMethodName:    auth.RoleAdmin,
+	proxypb.ProxyService_Start_FullMethodName:        auth.RoleOperator,
+	proxypb.ProxyService_Stop_FullMethodName:         auth.RoleOperator,
+	proxypb.ProxyService_Restart_FullMethodName:      auth.RoleOperator,
+	proxypb.ProxyService_GetStatus_FullMethodName:    auth.RoleViewer,
+	proxypb.ProxyService_GetData_FullMethodName:      auth.RoleViewer,
+	proxypb.ProxyService_ReportStatus_FullMethodName: auth.RoleDevice,
+}
+
+// identityKey context中保存调用方身份的键
+type identityKey struct{}
+
+// unaryInterceptor 认证并授权一元调用
+func (server *Server) unaryInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := server.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, request)
+}
+
+// streamInterceptor 认证并授权流式调用
+func (server *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := server.authorize(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identityStream{ServerStream: stream, ctx: ctx})
+}
+
+// authorize 认证调用方并检查角色，返回携带调用方身份的context
+func (server *Server) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	if server.authenticator == nil {
+		return ctx, nil
+	}
+
+	required, ok := methodRoles[fullMethod]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+
+	request := httpRequest(ctx, fullMethod)
+	var identity *auth.Identity
+	if required == auth.RoleDevice {
+		// 状态上报只接受启动时注入的 PROXY_APP_TOKEN
+		if !server.manager.VerifyAppToken(auth.BearerToken(request)) {
+			server.logger.Warnf("Rejected gRPC status report with invalid app token")
+			return nil, status.Error(codes.Unauthenticated, "unauthorized")
+		}
+		identity = &auth.Identity{
+			Subject: server.manager.GetStatus().AppName,
+			Method:  auth.MethodAppToken,
+			Role:    auth.RoleDevice,
+		}
+	} else {
+		var err error
+		identity, err = server.authenticator.Authenticate(request)
+		if err != nil {
+			server.logger.Warnf("Authentication failed for %s: %v", fullMethod, err)
+			return nil, status.Error(codes.Unauthenticated, "unauthorized")
+		}
+	}
+
+	if !auth.Allows(identity.Role, required) {
+		server.logger.Warnf("Forbidden %s for %s (role %q, requires %q)", fullMethod, identity.Subject, identity.Role, required)
+		return nil, status.Errorf(codes.PermissionDenied, "insufficient role %q, requires %q", identity.Role, required)
+	}
+	return context.WithValue(ctx, identityKey{}, identity), nil
+}
+
+// actorFrom 获取记录到生命周期历史中的调用方名称
+func actorFrom(ctx context.Context) string {
+	if identity, ok := ctx.Value(identityKey{}).(*auth.Identity); ok {
+		return identity.Subject
+	}
+	return "anonymous"
+}
+
+// identityStream 替换流的context以携带调用方身份
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context 实现 grpc.ServerStream
+func (stream *identityStream) Context() context.Context {
+	return stream.ctx
+}
@@ -0,0 +1,49 @@
+package grpcapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"brick-smart-template/pkg/models"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain gRPC错误详情中的 ErrorInfo.domain
+const errorDomain = "brick-proxy"
+
+// grpcCodes HTTP状态码到gRPC状态码的映射
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.FailedPrecondition,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+// grpcError 将API错误转换为gRPC状态，错误码放在 ErrorInfo.reason 中
+func grpcError(err error) error {
+	apiErr := models.AsAPIError(err)
+	code, ok := grpcCodes[apiErr.HTTPStatus]
+	if !ok {
+		code = codes.Unknown
+	}
+
+	info := &errdetails.ErrorInfo{Reason: apiErr.Code, Domain: errorDomain}
+	if len(apiErr.Details) > 0 {
+		info.Metadata = make(map[string]string, len(apiErr.Details))
+		for key, value := range apiErr.Details {
+			info.Metadata[key] = fmt.Sprint(value)
+		}
+	}
+	st, detailErr := status.New(code, apiErr.Message).WithDetails(info)
+	if detailErr != nil {
+		return status.Error(code, apiErr.Message)
+	}
+	return st.Err()
+}
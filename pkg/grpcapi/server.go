@@ -0,0 +1,247 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+
+	"brick-smart-template/pkg/appmanager"
+	"brick-smart-template/pkg/auth"
+	"brick-smart-template/pkg/grpcapi/proxypb"
+	"brick-smart-template/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server gRPC API服务器，接口与HTTP API一一对应
+type Server struct {
+	proxypb.UnimplementedProxyServiceServer
+
+	server        *grpc.Server
+	manager       *appmanager.Manager
+	logger        *logrus.Logger
+	authenticator auth.Authenticator // 为nil时不启用认证
+}
+
+// NewServer 创建新的gRPC服务器，tlsConfig为nil时使用明文
+func NewServer(manager *appmanager.Manager, logger *logrus.Logger, tlsConfig *tls.Config) *Server {
+	server := &Server{
+		manager: manager,
+		logger:  logger,
+	}
+
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(server.unaryInterceptor),
+		grpc.StreamInterceptor(server.streamInterceptor),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server.server = grpc.NewServer(options...)
+	proxypb.RegisterProxyServiceServer(server.server, server)
+	return server
+}
+
+// SetAuthenticator 设置认证器，nil表示不启用认证
+func (server *Server) SetAuthenticator(authenticator auth.Authenticator) {
+	server.authenticator = authenticator
+}
+
+// Run 启动gRPC服务器
+func (server *Server) Run(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server.logger.Infof("Starting gRPC server on %s", addr)
+	return server.server.Serve(listener)
+}
+
+// Shutdown 停止接收新请求并等待进行中的请求结束
+func (server *Server) Shutdown() {
+	server.server.GracefulStop()
+}
+
+// Configure 配置应用
+func (server *Server) Configure(ctx context.Context, request *proxypb.ConfigureRequest) (*proxypb.ConfigureResponse, error) {
+	appInfo, err := server.manager.ConfigureAppPayload(actorFrom(ctx), request.AppInfoJson, request.Signature)
+	if err != nil {
+		server.logger.Errorf("Failed to configure app: %v", err)
+		return nil, grpcError(err)
+	}
+	return &proxypb.ConfigureResponse{Status: "configured", AppName: appInfo.Name}, nil
+}
+
+// Start 启动应用
+func (server *Server) Start(ctx context.Context, request *proxypb.StartRequest) (*proxypb.StartResponse, error) {
+	// 校验 app_name 和 id
+	if err := server.manager.CheckTarget(request.Id, request.AppName); err != nil {
+		return nil, grpcError(err)
+	}
+
+	response, err := server.manager.StartApp(actorFrom(ctx), request.Profile)
+	if err != nil {
+		server.logger.Errorf("Failed to start app: %v", err)
+		return nil, grpcError(err)
+	}
+	return &proxypb.StartResponse{
+		Status:  response.Status,
+		AppName: response.AppName,
+		Pid:     int32(response.PID),
+		Profile: response.Profile,
+	}, nil
+}
+
+// Stop 停止应用
+func (server *Server) Stop(ctx context.Context, _ *proxypb.StopRequest) (*proxypb.StopResponse, error) {
+	response, err := server.manager.StopApp(actorFrom(ctx))
+	if err != nil {
+		server.logger.Errorf("Failed to stop app: %v", err)
+		return nil, grpcError(err)
+	}
+	return &proxypb.StopResponse{Status: response.Status}, nil
+}
+
+// Restart 重启应用
+func (server *Server) Restart(ctx context.Context, _ *proxypb.RestartRequest) (*proxypb.RestartResponse, error) {
+	response, err := server.manager.RestartApp(actorFrom(ctx))
+	if err != nil {
+		server.logger.Errorf("Failed to restart app: %v", err)
+		return nil, grpcError(err)
+	}
+	return &proxypb.RestartResponse{
+		Status:  response.Status,
+		AppName: response.AppName,
+		Pid:     int32(response.PID),
+		Profile: response.Profile,
+	}, nil
+}
+
+// GetStatus 获取应用状态
+func (server *Server) GetStatus(context.Context, *proxypb.GetStatusRequest) (*proxypb.GetStatusResponse, error) {
+	appStatus := server.manager.GetStatus()
+	config, err := toStruct(appStatus.Config)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &proxypb.GetStatusResponse{
+		ProcessId:    server.manager.ProxyID(),
+		AppName:      appStatus.AppName,
+		Status:       appStatus.Status,
+		RestartCount: int32(appStatus.RestartCount),
+		Config:       config,
+		Env:          appStatus.Env,
+	}
+	if appStatus.PID != nil {
+		response.Pid = int32(*appStatus.PID)
+	}
+	if appStatus.StartTime != nil {
+		response.StartTime = timestamppb.New(*appStatus.StartTime)
+	}
+	if appStatus.StopTime != nil {
+		response.StopTime = timestamppb.New(*appStatus.StopTime)
+	}
+	if appStatus.LastError != nil {
+		response.LastError = *appStatus.LastError
+	}
+	return response, nil
+}
+
+// GetData 获取应用内部状态
+func (server *Server) GetData(context.Context, *proxypb.GetDataRequest) (*proxypb.GetDataResponse, error) {
+	data, err := toStruct(server.manager.GetInternalStatus())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proxypb.GetDataResponse{ProcessId: server.manager.ProxyID(), Data: data}, nil
+}
+
+// ReportStatus 接收应用的状态上报流，每条被接受的报告都会更新内部状态
+// 单条报告不符合schema时记录日志并计数，继续接收后续报告
+func (server *Server) ReportStatus(stream proxypb.ProxyService_ReportStatusServer) error {
+	var received, rejected int64
+	for {
+		report, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&proxypb.ReportStatusResponse{Received: received, Rejected: rejected})
+		}
+		if err != nil {
+			return err
+		}
+
+		statusReport := models.StatusReport{
+			DeviceID:      report.DeviceId,
+			DeviceType:    report.DeviceType,
+			Sequence:      report.Sequence,
+			SchemaVersion: report.SchemaVersion,
+			Status:        report.Status,
+			Data:          report.Data.AsMap(),
+		}
+		if report.Timestamp != nil {
+			timestamp := report.Timestamp.AsTime()
+			statusReport.Timestamp = &timestamp
+		}
+		if _, _, err := server.manager.ReportStatus(statusReport); err != nil {
+			// device_id 不一致说明上报方身份有误，结束整个流
+			if models.AsAPIError(err).Code != models.ErrorCodeSchemaViolation {
+				server.logger.Errorf("Status report stream failed: %v", err)
+				return grpcError(err)
+			}
+			server.logger.Warnf("Rejected status report: %v", err)
+			rejected++
+			continue
+		}
+		server.logger.Infof("Received status report: %s", statusReport.Status)
+		received++
+	}
+}
+
+// toStruct 将JSON对象转换为 google.protobuf.Struct，经JSON往返以规范化数值等类型
+func toStruct(value map[string]interface{}) (*structpb.Struct, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(normalized)
+}
+
+// httpRequest 将gRPC元数据转换为HTTP请求，以复用HTTP API的认证器
+// 只传递 authorization 元数据和TLS连接状态，即支持bearer token和mTLS
+func httpRequest(ctx context.Context, fullMethod string) *http.Request {
+	request := &http.Request{
+		Method: http.MethodPost,
+		Header: make(http.Header),
+	}
+	request.RequestURI = fullMethod
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get("authorization") {
+			request.Header.Add("Authorization", value)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state := tlsInfo.State
+			request.TLS = &state
+		}
+	}
+	return request.WithContext(ctx)
+}
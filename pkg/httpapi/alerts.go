@@ -0,0 +1,29 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getAlerts 列出告警，?state= 只返回该状态（pending、firing、resolved）的告警
+func (server *Server) getAlerts(c *gin.Context) {
+	alerts, err := server.manager.Alerts(c.Query("state"))
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	server.respond(c, http.StatusOK, alerts)
+}
+
+// reloadAlertRules 重新读取配置文件中的告警规则，规则无效时保留原有规则
+func (server *Server) reloadAlertRules(c *gin.Context) {
+	rules, err := server.manager.ReloadAlertRules()
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	server.respond(c, http.StatusOK, models.AlertRulesReloaded{Rules: rules})
+}
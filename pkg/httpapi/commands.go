@@ -0,0 +1,107 @@
+package httpapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval SSE连接上发送心跳注释的间隔，避免反向代理因空闲断开连接
+const heartbeatInterval = 15 * time.Second
+
+// enqueueCommand 向应用下发命令
+func (server *Server) enqueueCommand(c *gin.Context) {
+	var request models.CommandRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		server.logger.Errorf("Invalid command request: %v", err)
+		server.abortWithError(c, invalidRequest(err))
+		return
+	}
+
+	command, err := server.manager.EnqueueCommand(actorFrom(c), request)
+	if err != nil {
+		server.logger.Errorf("Failed to enqueue command: %v", err)
+		server.abortWithError(c, err)
+		return
+	}
+	server.respond(c, http.StatusAccepted, command)
+}
+
+// getCommand 获取命令状态
+func (server *Server) getCommand(c *gin.Context) {
+	command, err := server.manager.GetCommand(c.Param("id"))
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	server.respond(c, http.StatusOK, command)
+}
+
+// streamCommands 以SSE向应用推送命令，每个命令为一个 command 事件
+func (server *Server) streamCommands(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	c.Stream(func(w io.Writer) bool {
+		waitCtx, cancel := context.WithTimeout(ctx, heartbeatInterval)
+		command, err := server.manager.NextCommand(waitCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		}
+
+		// 写出并刷新成功后才标记为已下发，否则放回队列等待应用重连
+		deliveredAt := time.Now()
+		command.Status = models.CommandStatusDelivered
+		command.DeliveredAt = &deliveredAt
+		err = sse.Encode(w, sse.Event{
+			Id:    command.ID,
+			Event: "command",
+			Data:  command,
+		})
+		if err == nil {
+			// gin 的 Flush 不返回写出错误，客户端断开由请求上下文取消体现
+			c.Writer.Flush()
+			err = ctx.Err()
+		}
+		if err != nil {
+			server.manager.ReleaseCommand(command.ID)
+			return false
+		}
+		server.manager.MarkCommandDelivered(command.ID, deliveredAt)
+		server.logger.Infof("Delivered command %s (%s) to app", command.Name, command.ID)
+		return true
+	})
+}
+
+// completeCommand 应用提交命令的执行结果
+func (server *Server) completeCommand(c *gin.Context) {
+	var request models.CommandResultRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		server.logger.Errorf("Invalid command result: %v", err)
+		server.abortWithError(c, invalidRequest(err))
+		return
+	}
+
+	command, err := server.manager.CompleteCommand(c.Param("id"), request)
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	server.respond(c, http.StatusOK, command)
+}
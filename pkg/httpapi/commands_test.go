@@ -0,0 +1,147 @@
+package httpapi
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brick-smart-template/pkg/appmanager"
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// newConfiguredServer 创建已配置应用（未启动）的测试服务器
+func newConfiguredServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	manager, err := appmanager.NewManager(logger, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.ConfigureApp("test", models.AppInfo{Name: "cleaner", Command: "/bin/true", HealthCheckInterval: 60}); err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(manager, logger)
+}
+
+// streamWriter 记录SSE输出的 ResponseWriter，onWrite 在每次写出后调用
+type streamWriter struct {
+	*httptest.ResponseRecorder
+	onWrite func()
+}
+
+// CloseNotify 实现 http.CloseNotifier，gin 的 Stream 需要
+func (w *streamWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+// Write 实现 http.ResponseWriter
+func (w *streamWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseRecorder.Write(p)
+	w.onWrite()
+	return n, err
+}
+
+// WriteString 实现 io.StringWriter
+func (w *streamWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// serveStream 以给定的请求上下文调用命令通道，返回处理结束的通知
+func serveStream(ctx context.Context, server *Server, w *streamWriter) <-chan struct{} {
+	router := gin.New()
+	router.GET("/commands/stream", server.streamCommands)
+	r := httptest.NewRequest("GET", "/commands/stream", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(w, r)
+	}()
+	return done
+}
+
+// commandStatus 返回命令的当前状态
+func commandStatus(t *testing.T, server *Server, id string) models.CommandStatus {
+	t.Helper()
+	command, err := server.manager.GetCommand(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return command.Status
+}
+
+func TestStreamCommandsDelivered(t *testing.T) {
+	server := newConfiguredServer(t)
+	command, err := server.manager.EnqueueCommand("test", models.CommandRequest{Name: "dock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &streamWriter{ResponseRecorder: httptest.NewRecorder(), onWrite: func() {}}
+	done := serveStream(ctx, server, w)
+	deadline := time.Now().Add(5 * time.Second)
+	for commandStatus(t, server, command.ID) != models.CommandStatusDelivered {
+		if time.Now().After(deadline) {
+			t.Fatal("command was not delivered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if !strings.Contains(w.Body.String(), "event:command") {
+		t.Errorf("stream output = %q", w.Body.String())
+	}
+}
+
+func TestStreamCommandsClientDropped(t *testing.T) {
+	server := newConfiguredServer(t)
+	command, err := server.manager.EnqueueCommand("test", models.CommandRequest{Name: "dock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 写出命令的同时客户端断开：数据进入了缓冲区，但请求上下文已取消
+	streamCtx, cancel := context.WithCancel(context.Background())
+	w := &streamWriter{ResponseRecorder: httptest.NewRecorder()}
+	w.onWrite = func() {
+		if strings.Contains(w.Body.String(), "event:command") {
+			cancel()
+		}
+	}
+	done := serveStream(streamCtx, server, w)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end after the client dropped")
+	}
+	if status := commandStatus(t, server, command.ID); status != models.CommandStatusPending {
+		t.Fatalf("status after dropped delivery = %s, want %s", status, models.CommandStatusPending)
+	}
+
+	// 重连的客户端收到同一个命令
+	ctx, stop := context.WithTimeout(context.Background(), time.Second)
+	defer stop()
+	next, err := server.manager.NextCommand(ctx)
+	if err != nil || next.ID != command.ID {
+		t.Fatalf("NextCommand() = %+v, %v, want %s", next, err, command.ID)
+	}
+
+	// 已断开的接收方不会取出命令
+	server.manager.ReleaseCommand(command.ID)
+	ctx, stop = context.WithCancel(context.Background())
+	stop()
+	if _, err := server.manager.NextCommand(ctx); err == nil {
+		t.Fatal("NextCommand() with a cancelled context returned a command")
+	}
+	if status := commandStatus(t, server, command.ID); status != models.CommandStatusPending {
+		t.Errorf("status = %s, want %s", status, models.CommandStatusPending)
+	}
+}
@@ -0,0 +1,124 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"brick-smart-template/pkg/models"
+	"brick-smart-template/pkg/timeseries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// revisionHeader 返回内部状态版本号的响应头，长轮询时作为 wait_for_revision 的依据
+const revisionHeader = "X-Revision"
+
+// getInternalStatus 获取应用内部状态
+// /v1 路由返回完整的上报（设备信封、received_at 和 data），旧路径只返回 data；
+// 响应带有按版本号生成的 ETag，If-None-Match 命中时返回304；
+// ?wait_for_revision=N 时阻塞到版本号达到N或超时（?timeout=，默认30s），超时返回当前数据
+func (server *Server) getInternalStatus(c *gin.Context) {
+	var (
+		report   *models.StatusReport
+		revision uint64
+	)
+	if value := c.Query("wait_for_revision"); value != "" {
+		waitFor, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			server.abortWithError(c, models.InvalidRequest(fmt.Sprintf("invalid wait_for_revision %q", value)))
+			return
+		}
+		timeout, err := parseTimeout(c)
+		if err != nil {
+			server.abortWithError(c, err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		report, revision = server.manager.WaitLatestReport(ctx, waitFor)
+	} else {
+		report, revision = server.manager.LatestReport()
+	}
+
+	etag := server.dataETag(revision)
+	c.Header("ETag", etag)
+	c.Header(revisionHeader, strconv.FormatUint(revision, 10))
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if isV1(c) {
+		server.respond(c, http.StatusOK, report)
+		return
+	}
+	c.JSON(http.StatusOK, report.Data)
+}
+
+// getDataHistory 查询内部状态中一个字段的历史
+// ?field= 必填；?from=&to= 为RFC 3339时间或Unix秒，默认最近1小时；?step=1m&agg=avg 时降采样
+func (server *Server) getDataHistory(c *gin.Context) {
+	query := timeseries.Query{Field: c.Query("field"), Agg: c.Query("agg")}
+	var err error
+	if query.From, err = parseTime(c, "from"); err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	if query.To, err = parseTime(c, "to"); err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	if value := c.Query("step"); value != "" {
+		if query.Step, err = time.ParseDuration(value); err != nil || query.Step <= 0 {
+			server.abortWithError(c, models.InvalidRequest(fmt.Sprintf("invalid step %q: must be a positive duration", value)))
+			return
+		}
+	}
+
+	history, err := server.manager.DataHistory(query)
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	server.respond(c, http.StatusOK, history)
+}
+
+// getDataStats 获取内部状态数值字段在各窗口内的统计，?field= 时只返回该字段
+func (server *Server) getDataStats(c *gin.Context) {
+	server.respond(c, http.StatusOK, server.manager.DataStats(c.Query("field")))
+}
+
+// parseTime 解析RFC 3339时间或Unix秒，参数为空时返回零值
+func parseTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, models.InvalidRequest(fmt.Sprintf("invalid %s %q: must be an RFC 3339 time or Unix seconds", name, value))
+	}
+	return time.Unix(0, int64(seconds*1e9)), nil
+}
+
+// dataETag 内部状态版本号对应的ETag，带上proxy启动时间避免重启后版本号重复
+func (server *Server) dataETag(revision uint64) string {
+	return fmt.Sprintf(`"%x-%d"`, server.started.UnixNano(), revision)
+}
+
+// etagMatches 判断 If-None-Match 是否包含指定的ETag（弱比较）
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// abortWithError 以统一的错误结构返回错误，状态码由错误类型决定
+func (server *Server) abortWithError(c *gin.Context, err error) {
+	apiErr := models.AsAPIError(err)
+	if apiErr.HTTPStatus >= http.StatusInternalServerError {
+		server.logger.Errorf("%s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+	c.AbortWithStatusJSON(apiErr.HTTPStatus, models.ErrorResponse{Error: apiErr})
+}
+
+// invalidRequest 请求体无法解析
+func invalidRequest(err error) error {
+	return models.InvalidRequest(err.Error())
+}
@@ -0,0 +1,184 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	replayedHeader    = "Idempotent-Replayed"
+
+	// DefaultIdempotencyTTL 首次响应的默认保留时间
+	DefaultIdempotencyTTL = 24 * time.Hour
+	maxIdempotencyKeys    = 1000
+	maxIdempotencyKeyLen  = 255
+)
+
+// replayedHeaders 重放时一并返回的响应头
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// idempotencyStore 按调用方和Idempotency-Key保存的首次响应
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotencyEntry
+}
+
+// idempotencyEntry 首次请求的指纹和响应，done为false表示仍在处理
+type idempotencyEntry struct {
+	fingerprint [sha256.Size]byte
+	done        bool
+	expires     time.Time
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+// newIdempotencyStore 创建Idempotency-Key存储
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, entries: make(map[string]*idempotencyEntry)}
+}
+
+// SetIdempotencyTTL 设置Idempotency-Key首次响应的保留时间
+func (server *Server) SetIdempotencyTTL(ttl time.Duration) {
+	server.idempotency.mu.Lock()
+	defer server.idempotency.mu.Unlock()
+	server.idempotency.ttl = ttl
+}
+
+// recordingWriter 在写出响应的同时保存响应体
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent 支持 Idempotency-Key 的变更类接口：重复请求直接重放首次响应，
+// 同一key对应不同的请求（方法、路径或请求体不同）时返回422
+func (server *Server) idempotent(c *gin.Context) {
+	key := c.GetHeader(idempotencyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		server.abortWithError(c, models.InvalidRequest("Idempotency-Key is too long"))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		server.abortWithError(c, invalidRequest(err))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// key按调用方隔离，避免不同调用方的key互相冲突
+	scope := actorFrom(c) + "\x00" + key
+	fingerprint := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.RequestURI()+"\n"), body...))
+
+	store := server.idempotency
+	store.mu.Lock()
+	now := time.Now()
+	entry, ok := store.entries[scope]
+	if ok && entry.done && now.After(entry.expires) {
+		delete(store.entries, scope)
+		ok = false
+	}
+	if ok {
+		store.mu.Unlock()
+		switch {
+		case entry.fingerprint != fingerprint:
+			server.abortWithError(c, models.NewAPIError(http.StatusUnprocessableEntity, models.ErrorCodeIdempotencyKeyReused,
+				"Idempotency-Key was used for a different request"))
+		case !entry.done:
+			server.abortWithError(c, models.NewAPIError(http.StatusConflict, models.ErrorCodeConflict,
+				"a request with this Idempotency-Key is still in progress"))
+		default:
+			for name, values := range entry.header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header(replayedHeader, "true")
+			c.Status(entry.status)
+			c.Writer.Write(entry.body)
+			c.Abort()
+		}
+		return
+	}
+	entry = &idempotencyEntry{fingerprint: fingerprint}
+	store.entries[scope] = entry
+	store.evict(now)
+	store.mu.Unlock()
+
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	completed := false
+	defer func() {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		// 处理中panic或服务端错误时不保存，允许用同一key重试
+		if !completed || writer.Status() >= http.StatusInternalServerError {
+			if store.entries[scope] == entry {
+				delete(store.entries, scope)
+			}
+			return
+		}
+		entry.complete(writer, store.ttl)
+	}()
+	c.Next()
+	completed = true
+}
+
+// complete 保存首次响应
+func (entry *idempotencyEntry) complete(writer *recordingWriter, ttl time.Duration) {
+	status := writer.Status()
+	entry.done = true
+	entry.expires = time.Now().Add(ttl)
+	entry.status = status
+	entry.body = writer.body.Bytes()
+	entry.header = make(http.Header)
+	for _, name := range replayedHeaders {
+		if value := writer.Header().Get(name); value != "" {
+			entry.header.Set(name, value)
+		}
+	}
+}
+
+// evict 清理过期的记录，超出数量上限时淘汰最早过期的记录，调用方需持有锁
+func (store *idempotencyStore) evict(now time.Time) {
+	for scope, entry := range store.entries {
+		if entry.done && now.After(entry.expires) {
+			delete(store.entries, scope)
+		}
+	}
+	for len(store.entries) > maxIdempotencyKeys {
+		var oldest string
+		for scope, entry := range store.entries {
+			if entry.done && (oldest == "" || entry.expires.Before(store.entries[oldest].expires)) {
+				oldest = scope
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		delete(store.entries, oldest)
+	}
+}
@@ -0,0 +1,179 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotentRouter 返回带 Idempotency-Key 支持的测试路由，handler 的调用次数记录在 calls 中
+func idempotentRouter(server *Server, calls *int32, handler gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	router.POST("/things/:name", server.idempotent, func(c *gin.Context) {
+		atomic.AddInt32(calls, 1)
+		handler(c)
+	})
+	return router
+}
+
+// postWithKey 发送带 Idempotency-Key 的请求
+func postWithKey(router http.Handler, target, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		r.Header.Set(idempotencyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	server := newConfiguredServer(t)
+	var calls int32
+	router := idempotentRouter(server, &calls, func(c *gin.Context) {
+		c.Header("Location", "/things/"+c.Param("name"))
+		c.JSON(http.StatusCreated, gin.H{"call": atomic.LoadInt32(&calls)})
+	})
+
+	first := postWithKey(router, "/things/a", "k1", `{"v":1}`)
+	second := postWithKey(router, "/things/a", "k1", `{"v":1}`)
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get(replayedHeader) != "true" || second.Header().Get("Location") != "/things/a" {
+		t.Errorf("replay headers = %v", second.Header())
+	}
+	if first.Header().Get(replayedHeader) != "" {
+		t.Errorf("first response marked as replayed")
+	}
+
+	// 不带key或使用新key的请求正常执行
+	postWithKey(router, "/things/a", "", `{"v":1}`)
+	postWithKey(router, "/things/a", "k2", `{"v":1}`)
+	if calls != 3 {
+		t.Errorf("handler called %d times, want 3", calls)
+	}
+}
+
+func TestIdempotentKeyReused(t *testing.T) {
+	server := newConfiguredServer(t)
+	var calls int32
+	router := idempotentRouter(server, &calls, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	postWithKey(router, "/things/a", "k1", `{"v":1}`)
+	for _, tt := range []struct{ target, body string }{
+		{"/things/a", `{"v":2}`},
+		{"/things/b", `{"v":1}`},
+		{"/things/a?force=true", `{"v":1}`},
+	} {
+		w := postWithKey(router, tt.target, "k1", tt.body)
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "idempotency_key_reused") {
+			t.Errorf("%s %s: status %d: %s", tt.target, tt.body, w.Code, w.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+
+	if w := postWithKey(router, "/things/a", strings.Repeat("k", maxIdempotencyKeyLen+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("long key: status %d, want 400", w.Code)
+	}
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	server := newConfiguredServer(t)
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router := idempotentRouter(server, &calls, func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- postWithKey(router, "/things/a", "k1", `{}`)
+	}()
+	<-started
+	if w := postWithKey(router, "/things/a", "k1", `{}`); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "conflict") {
+		t.Errorf("concurrent request: status %d: %s", w.Code, w.Body.String())
+	}
+	close(release)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	if w := postWithKey(router, "/things/a", "k1", `{}`); w.Code != http.StatusOK || w.Header().Get(replayedHeader) != "true" {
+		t.Errorf("after completion: status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestIdempotentServerErrorNotStored(t *testing.T) {
+	server := newConfiguredServer(t)
+	var calls int32
+	router := idempotentRouter(server, &calls, func(c *gin.Context) {
+		if atomic.LoadInt32(&calls) == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	if w := postWithKey(router, "/things/a", "k1", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	// 服务端错误不保存，同一key可以重试
+	if w := postWithKey(router, "/things/a", "k1", `{}`); w.Code != http.StatusOK || w.Header().Get(replayedHeader) != "" {
+		t.Fatalf("retry: status %d, headers %v", w.Code, w.Header())
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+// TestIdempotentClientErrorStored 4xx 响应是确定的结果，会被保存和重放
+func TestIdempotentClientErrorStored(t *testing.T) {
+	server := newConfiguredServer(t)
+	var calls int32
+	router := idempotentRouter(server, &calls, func(c *gin.Context) {
+		c.JSON(http.StatusBadRequest, gin.H{})
+	})
+
+	postWithKey(router, "/things/a", "k1", `{}`)
+	if w := postWithKey(router, "/things/a", "k1", `{}`); w.Code != http.StatusBadRequest || w.Header().Get(replayedHeader) != "true" {
+		t.Errorf("replay of 400: status %d, headers %v", w.Code, w.Header())
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestIdempotentExpiry(t *testing.T) {
+	server := newConfiguredServer(t)
+	server.SetIdempotencyTTL(10 * time.Millisecond)
+	var calls int32
+	router := idempotentRouter(server, &calls, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	postWithKey(router, "/things/a", "k1", `{"v":1}`)
+	time.Sleep(20 * time.Millisecond)
+	// 过期后同一key可以用于不同的请求
+	if w := postWithKey(router, "/things/a", "k1", `{"v":2}`); w.Code != http.StatusOK || w.Header().Get(replayedHeader) != "" {
+		t.Errorf("after expiry: status %d, headers %v", w.Code, w.Header())
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,306 @@
+package httpapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"brick-smart-template/pkg/auth"
+	"brick-smart-template/pkg/models"
+	"brick-smart-template/pkg/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apidocs 提交到仓库的OpenAPI文档和查看页面，修改路由或模型后运行 make openapi 重新生成
+//
+//go:embed apidocs/openapi.json apidocs/index.html
+var apidocs embed.FS
+
+// routeDoc 路由的文档描述，每个注册的路由都必须有对应的描述
+type routeDoc struct {
+	Summary     string
+	Tag         string
+	Role        string // 启用认证时要求的角色，为空表示无需认证
+	Query       []openapi.Parameter
+	Request     interface{}            // 请求体类型
+	Requests    map[string]interface{} // 按内容类型区分的请求体类型，设置时替代 Request
+	Response    interface{}            // 成功响应类型，/v1 路由包装在 Envelope 的 data 中
+	Legacy      interface{}            // 旧路径的响应类型与 /v1 不同时设置
+	Status      int                    // 成功状态码，默认200
+	Waited      interface{}            // ?wait=true 时操作完成后的200响应类型
+	ContentType string                 // 成功响应的内容类型，默认 application/json
+}
+
+var (
+	waitParams = []openapi.Parameter{
+		{Name: "wait", In: "query", Description: "为true时等待操作完成", Schema: &openapi.Schema{Type: "boolean"}},
+		{Name: "timeout", In: "query", Description: "等待的最长时间，如 30s，默认30s，最长5m", Schema: &openapi.Schema{Type: "string"}},
+	}
+	idempotencyParam = openapi.Parameter{
+		Name: idempotencyHeader, In: "header", Description: "重试时使用相同的key，重复请求重放首次响应", Schema: &openapi.Schema{Type: "string"},
+	}
+)
+
+// routeDocs 按 "方法 路径" 索引的路由描述，/v1 路由和对应的旧路径共用一个描述（路径不含 /v1 前缀）
+var routeDocs = map[string]routeDoc{
+	"GET /health": {Summary: "健康检查", Tag: "system", Response: models.HealthCheckResponse{}},
+	"GET /metrics": {Summary: "Prometheus指标", Tag: "system", Role: auth.RoleViewer,
+		ContentType: "text/plain"},
+	"GET /openapi.json": {Summary: "OpenAPI文档", Tag: "system", Response: map[string]interface{}{}},
+	"GET /docs":         {Summary: "API文档查看页面", Tag: "system", ContentType: "text/html"},
+	"GET /operations/:id": {Summary: "查询生命周期操作", Tag: "app", Role: auth.RoleViewer,
+		Response: models.Operation{}},
+
+	"GET /deprecations": {Summary: "旧路径的使用情况", Tag: "system", Role: auth.RoleViewer,
+		Response: []models.DeprecatedRoute{}},
+
+	"GET /app/status": {Summary: "应用状态", Tag: "app", Role: auth.RoleViewer, Response: models.AppStatusResponse{}, Legacy: struct {
+		ProcessID string                    `json:"process_id"`
+		Status    *models.AppStatusResponse `json:"status"`
+	}{}},
+	"GET /app/data": {Summary: "应用最近一次上报的设备信封和内部状态，支持 ETag 和长轮询", Tag: "app", Role: auth.RoleViewer,
+		Query: []openapi.Parameter{
+			{Name: "wait_for_revision", In: "query", Description: "阻塞到内部状态版本号达到该值", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+			{Name: "timeout", In: "query", Description: "长轮询的最长等待时间，如 30s，默认30s，最长5m", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "If-None-Match", In: "header", Description: "上次响应的 ETag，未变化时返回304", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Response: models.StatusReport{}, Legacy: map[string]interface{}{}},
+	"GET /app/data/history": {Summary: "内部状态中一个字段的历史，可按时间降采样", Tag: "app", Role: auth.RoleViewer,
+		Query: []openapi.Parameter{
+			{Name: "field", In: "query", Description: "字段名，嵌套字段以 . 连接", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			{Name: "from", In: "query", Description: "起始时间，RFC 3339 或 Unix 秒，默认 to 之前1小时", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "to", In: "query", Description: "结束时间，RFC 3339 或 Unix 秒，默认当前时间", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "step", In: "query", Description: "降采样的桶宽度，如 1m，不指定时返回原始样本", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "agg", In: "query", Description: "桶内的聚合方式，数值字段默认 avg，字符串字段默认 last", Schema: &openapi.Schema{Type: "string", Enum: []string{"avg", "min", "max", "sum", "count", "first", "last"}}},
+		},
+		Response: models.DataHistory{}},
+	"GET /app/data/stats": {Summary: "内部状态数值字段在各窗口内的滚动统计", Tag: "app", Role: auth.RoleViewer,
+		Query: []openapi.Parameter{
+			{Name: "field", In: "query", Description: "只返回该字段", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Response: models.DataStats{}},
+
+	"GET /alerts": {Summary: "告警列表", Tag: "alerts", Role: auth.RoleViewer,
+		Query: []openapi.Parameter{
+			{Name: "state", In: "query", Description: "只返回该状态的告警", Schema: &openapi.Schema{Type: "string",
+				Enum: []string{models.AlertStatePending, models.AlertStateFiring, models.AlertStateResolved}}},
+		},
+		Response: []models.Alert{}},
+	"POST /alerts/reload": {Summary: "重新加载告警规则", Tag: "alerts", Role: auth.RoleAdmin,
+		Response: models.AlertRulesReloaded{}},
+	"GET /app/process": {Summary: "应用状态和内部状态", Tag: "app", Role: auth.RoleViewer, Response: models.ProcessView{}, Legacy: struct {
+		AppName       string                    `json:"app_name"`
+		ProcessID     string                    `json:"process_id"`
+		ProcessStatus *models.AppStatusResponse `json:"process_status"`
+		ProcessData   map[string]interface{}    `json:"process_data"`
+	}{}},
+	"GET /app/history": {Summary: "生命周期历史", Tag: "app", Role: auth.RoleViewer, Response: []models.LifecycleEvent{}, Legacy: struct {
+		ProcessID string                  `json:"process_id"`
+		History   []models.LifecycleEvent `json:"history"`
+	}{}},
+	"GET /app/commands/:id": {Summary: "查询命令", Tag: "app", Role: auth.RoleViewer, Response: models.Command{}},
+	"GET /app/stream": {Summary: "生命周期、状态和告警事件流（SSE）", Tag: "app", Role: auth.RoleViewer,
+		Query: []openapi.Parameter{
+			{Name: "last_event_id", In: "query", Description: "从该事件之后开始重放，也可使用 Last-Event-ID 请求头", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		},
+		Response: models.StreamEvent{}, ContentType: "text/event-stream"},
+	"GET /app/logs/stream": {Summary: "应用输出（SSE）", Tag: "app", Role: auth.RoleOperator,
+		Query: []openapi.Parameter{
+			{Name: "last_event_id", In: "query", Description: "从该事件之后开始重放，也可使用 Last-Event-ID 请求头", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		},
+		Response: models.StreamEvent{}, ContentType: "text/event-stream"},
+	"GET /app/ws": {Summary: "WebSocket订阅和控制", Tag: "app", Role: auth.RoleViewer, Status: http.StatusSwitchingProtocols},
+
+	"POST /app/start": {Summary: "启动应用", Tag: "app", Role: auth.RoleOperator, Query: waitParams,
+		Request: models.StartAppRequest{}, Response: models.Operation{}, Status: http.StatusAccepted, Waited: models.StartAppResponse{}},
+	"POST /app/restart": {Summary: "重启应用", Tag: "app", Role: auth.RoleOperator, Query: waitParams,
+		Request: models.RestartAppRequest{}, Response: models.Operation{}, Status: http.StatusAccepted, Waited: models.RestartAppResponse{}},
+	"POST /app/stop": {Summary: "停止应用", Tag: "app", Role: auth.RoleOperator, Query: waitParams,
+		Response: models.Operation{}, Status: http.StatusAccepted, Waited: models.StopAppResponse{}},
+	"POST /app/command": {Summary: "向应用下发命令", Tag: "app", Role: auth.RoleOperator,
+		Request: models.CommandRequest{}, Response: models.Command{}, Status: http.StatusAccepted},
+	"POST /app/configure": {Summary: "配置应用", Tag: "app", Role: auth.RoleAdmin,
+		Request: models.ConfigureAppRequest{}, Response: models.ConfigureAppResponse{}},
+
+	"POST /app/status/report": {Summary: "应用上报状态", Tag: "device", Role: auth.RoleDevice,
+		Request: models.ReportStatusRequest{}, Response: models.ReportStatusResponse{}},
+	"PATCH /app/status/report": {Summary: "应用增量上报状态（JSON Merge Patch 或 JSON Patch）", Tag: "device", Role: auth.RoleDevice,
+		Requests: map[string]interface{}{
+			mergePatchContentType: map[string]interface{}{},
+			jsonPatchContentType:  []models.JSONPatchOperation{},
+		},
+		Response: models.ReportStatusResponse{}},
+	"GET /app/commands/stream": {Summary: "应用接收命令（SSE）", Tag: "device", Role: auth.RoleDevice,
+		Response: models.Command{}, ContentType: "text/event-stream"},
+	"POST /app/commands/:id/result": {Summary: "应用回执命令结果", Tag: "device", Role: auth.RoleDevice,
+		Request: models.CommandResultRequest{}, Response: models.Command{}},
+}
+
+// pathParam 匹配gin路由中的路径参数
+var pathParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// OpenAPI 根据已注册的路由和 routeDocs 生成OpenAPI文档，路由缺少描述或描述没有对应路由时返回错误
+func (server *Server) OpenAPI() (*openapi.Document, error) {
+	generator := openapi.NewGenerator()
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Brick Proxy API",
+			Description: "错误统一以 ErrorResponse 返回，见 docs/api.md",
+			Version:     "1.0.0",
+		},
+		Paths: make(map[string]openapi.PathItem),
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+	errorSchema := generator.Schema(models.ErrorResponse{})
+
+	routes := server.router.Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path+routes[i].Method < routes[j].Path+routes[j].Method
+	})
+	registered := make(map[string]bool)
+	for _, route := range routes {
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	documented := make(map[string]bool)
+	for _, route := range routes {
+		versioned := strings.HasPrefix(route.Path, apiV1Prefix+"/")
+		legacy := !versioned && registered[route.Method+" "+apiV1Prefix+route.Path]
+		key := route.Method + " " + strings.TrimPrefix(route.Path, apiV1Prefix)
+		if !versioned {
+			key = route.Method + " " + route.Path
+		}
+		documented[key] = true
+		routeDoc, ok := routeDocs[key]
+		if !ok {
+			return nil, fmt.Errorf("route %s %s is not documented in routeDocs", route.Method, route.Path)
+		}
+
+		// 成功响应的schema，/v1 路由包装为 Envelope
+		bodySchema := func(v interface{}) *openapi.Schema {
+			schema := generator.Schema(v)
+			if !versioned || schema == nil || routeDoc.ContentType != "" {
+				return schema
+			}
+			return &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{
+				"data": schema,
+				"meta": generator.Schema(models.EnvelopeMeta{}),
+			}}
+		}
+		responseType := routeDoc.Response
+		if legacy && routeDoc.Legacy != nil {
+			responseType = routeDoc.Legacy
+		}
+
+		operation := &openapi.Operation{
+			Deprecated:   legacy,
+			Summary:      routeDoc.Summary,
+			Tags:         []string{routeDoc.Tag},
+			RequiredRole: routeDoc.Role,
+			Responses: map[string]*openapi.Response{
+				"default": {Description: "错误", Content: map[string]*openapi.MediaType{"application/json": {Schema: errorSchema}}},
+			},
+		}
+		if routeDoc.Role != "" {
+			operation.Security = []map[string][]string{{"bearerAuth": {}}}
+		}
+		for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+			operation.Parameters = append(operation.Parameters, openapi.Parameter{
+				Name: match[1], In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+		operation.Parameters = append(operation.Parameters, routeDoc.Query...)
+		if route.Method != http.MethodGet {
+			operation.Parameters = append(operation.Parameters, idempotencyParam)
+		}
+		if routeDoc.Request != nil {
+			operation.RequestBody = &openapi.RequestBody{
+				Required: true,
+				Content:  map[string]*openapi.MediaType{"application/json": {Schema: generator.Schema(routeDoc.Request)}},
+			}
+		}
+		if routeDoc.Requests != nil {
+			operation.RequestBody = &openapi.RequestBody{Required: true, Content: make(map[string]*openapi.MediaType)}
+			for contentType, request := range routeDoc.Requests {
+				operation.RequestBody.Content[contentType] = &openapi.MediaType{Schema: generator.Schema(request)}
+			}
+		}
+
+		status := routeDoc.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		contentType := routeDoc.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		response := &openapi.Response{Description: http.StatusText(status)}
+		if responseType != nil || routeDoc.ContentType != "" {
+			response.Content = map[string]*openapi.MediaType{contentType: {Schema: bodySchema(responseType)}}
+		}
+		operation.Responses[fmt.Sprint(status)] = response
+		if routeDoc.Waited != nil {
+			operation.Responses["200"] = &openapi.Response{
+				Description: "wait=true 且操作在超时前完成",
+				Content:     map[string]*openapi.MediaType{"application/json": {Schema: bodySchema(routeDoc.Waited)}},
+			}
+		}
+
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(openapi.PathItem)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	for key := range routeDocs {
+		if !documented[key] {
+			return nil, fmt.Errorf("routeDocs entry %s has no registered route", key)
+		}
+	}
+	doc.Components.Schemas = generator.Schemas()
+	return doc, nil
+}
+
+// MarshalOpenAPI 生成格式化的OpenAPI文档，与 apidocs/openapi.json 的格式一致
+func (server *Server) MarshalOpenAPI() ([]byte, error) {
+	doc, err := server.OpenAPI()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// getOpenAPI 返回提交到仓库的OpenAPI文档
+func (server *Server) getOpenAPI(c *gin.Context) {
+	data, err := apidocs.ReadFile("apidocs/openapi.json")
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// getAPIDocs 返回内置的文档查看页面
+func (server *Server) getAPIDocs(c *gin.Context) {
+	data, err := apidocs.ReadFile("apidocs/index.html")
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+}
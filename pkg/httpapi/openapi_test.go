@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"testing"
+
+	"brick-smart-template/pkg/appmanager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var updateSpec = flag.Bool("update", false, "regenerate apidocs/openapi.json")
+
+// TestOpenAPISpec 修改路由或模型后需要重新生成提交到仓库的OpenAPI文档：
+//
+//	go test ./pkg/httpapi -run TestOpenAPISpec -update
+func TestOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	manager, err := appmanager.NewManager(logger, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(manager, logger)
+
+	generated, err := server.MarshalOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const path = "apidocs/openapi.json"
+	if *updateSpec {
+		if err := os.WriteFile(path, generated, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	committed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(committed, generated) {
+		t.Fatalf("%s is out of date, run: go test ./pkg/httpapi -run TestOpenAPISpec -update", path)
+	}
+}
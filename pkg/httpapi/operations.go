@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+)
+
+// runOperation 在后台执行生命周期操作
+// /v1 路由默认返回202和操作ID；?wait=true 时等待完成（最长 ?timeout=，默认30s），成功返回原同步接口的响应，超时仍返回202
+// 旧路径保持同步接口的行为，默认等待完成（timeout 默认5m），?wait=false 时返回202
+func (server *Server) runOperation(c *gin.Context, operationType string, run func() (interface{}, error)) {
+	wait, timeout, err := parseWait(c)
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+
+	operation, err := server.manager.RunOperation(operationType, actorFrom(c), func() (interface{}, error) {
+		result, err := run()
+		if err != nil {
+			server.logger.Errorf("Failed to %s app: %v", operationType, err)
+		}
+		return result, err
+	})
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	location := "/operations/" + operation.ID
+	if isV1(c) {
+		location = apiV1Prefix + location
+	}
+	c.Header("Location", location)
+
+	if wait {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		if operation, err = server.manager.WaitOperation(ctx, operation.ID); err != nil {
+			server.abortWithError(c, err)
+			return
+		}
+		switch operation.Status {
+		case models.OperationStatusSucceeded:
+			server.respond(c, http.StatusOK, operation.Result)
+			return
+		case models.OperationStatusFailed:
+			server.abortWithError(c, operation.Error.WithDetail("operation_id", operation.ID))
+			return
+		}
+	}
+	server.respond(c, http.StatusAccepted, operation)
+}
+
+// parseWait 解析 wait 和 timeout 查询参数，旧路径默认同步等待
+func parseWait(c *gin.Context) (bool, time.Duration, error) {
+	wait := !isV1(c)
+	if value := c.Query("wait"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, 0, models.InvalidRequest(fmt.Sprintf("invalid wait %q", value))
+		}
+		wait = parsed
+	}
+
+	timeout, err := parseTimeout(c)
+	if err != nil {
+		return false, 0, err
+	}
+	if !isV1(c) && c.Query("timeout") == "" {
+		timeout = maxWaitTimeout
+	}
+	return wait, timeout, nil
+}
+
+// parseTimeout 解析等待类请求的 timeout 查询参数，默认30s，最长5m
+func parseTimeout(c *gin.Context) (time.Duration, error) {
+	value := c.Query("timeout")
+	if value == "" {
+		return defaultWaitTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 || timeout > maxWaitTimeout {
+		return 0, models.InvalidRequest(fmt.Sprintf("invalid timeout %q: must be a duration up to %s", value, maxWaitTimeout))
+	}
+	return timeout, nil
+}
+
+// getOperation 查询生命周期操作状态
+func (server *Server) getOperation(c *gin.Context) {
+	operation, err := server.manager.GetOperation(c.Param("id"))
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	server.respond(c, http.StatusOK, operation)
+}
@@ -0,0 +1,129 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve 向服务器发送请求并返回响应
+func serve(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// decodeOperation 解码 /v1 响应信封中的操作
+func decodeOperation(t *testing.T, w *httptest.ResponseRecorder) models.Operation {
+	t.Helper()
+	var envelope struct {
+		Data models.Operation `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+	}
+	return envelope.Data
+}
+
+func TestOperationAccepted(t *testing.T) {
+	server := newConfiguredServer(t)
+	w := serve(server.router, "POST", "/v1/app/start", `{"profile":"{}"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202: %s", w.Code, w.Body.String())
+	}
+	operation := decodeOperation(t, w)
+	if operation.ID == "" || operation.Type != "start" {
+		t.Fatalf("operation = %+v", operation)
+	}
+	location := w.Header().Get("Location")
+	if location != "/v1/operations/"+operation.ID {
+		t.Fatalf("Location = %q", location)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w = serve(server.router, "GET", location, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", location, w.Code, w.Body.String())
+		}
+		if operation = decodeOperation(t, w); operation.Status != models.OperationStatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("operation did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if operation.Status != models.OperationStatusSucceeded || operation.Result == nil {
+		t.Errorf("finished operation = %+v", operation)
+	}
+
+	if w := serve(server.router, "GET", "/v1/operations/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown operation: status %d, want 404", w.Code)
+	}
+}
+
+func TestLegacyOperationIsSynchronous(t *testing.T) {
+	server := newConfiguredServer(t)
+	w := serve(server.router, "POST", "/app/start", `{"profile":"{}"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var response models.StartAppResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.PID == 0 {
+		t.Fatalf("response = %s", w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Location"), "/operations/") {
+		t.Errorf("Location = %q", w.Header().Get("Location"))
+	}
+
+	// 旧路径可以显式选择异步执行
+	w = serve(server.router, "POST", "/app/stop?wait=false", "")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("wait=false: status %d, want 202: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestOperationWaitTimeout(t *testing.T) {
+	server := newConfiguredServer(t)
+	release := make(chan struct{})
+	router := gin.New()
+	router.POST("/v1/slow", server.versioned, func(c *gin.Context) {
+		server.runOperation(c, "restart", func() (interface{}, error) {
+			<-release
+			return gin.H{"done": true}, nil
+		})
+	})
+
+	// 超时前未完成时仍返回202，可以继续轮询
+	w := serve(router, "POST", "/v1/slow?wait=true&timeout=50ms", "")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202: %s", w.Code, w.Body.String())
+	}
+	operation := decodeOperation(t, w)
+	if operation.Status != models.OperationStatusRunning {
+		t.Fatalf("operation = %+v", operation)
+	}
+
+	// 在超时内完成时返回原同步接口的响应
+	close(release)
+	w = serve(router, "POST", "/v1/slow?wait=true", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"done":true`) {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, query := range []string{"wait=maybe", "timeout=0s", "timeout=10m", "timeout=soon"} {
+		if w := serve(router, "POST", "/v1/slow?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package httpapi
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxPeerAncestry 向上查找父进程的最大层数
+const maxPeerAncestry = 8
+
+// peerPID 通过 SO_PEERCRED 获取Unix socket对端进程的PID
+func peerPID(conn net.Conn) (int, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, fmt.Errorf("not a unix socket connection")
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, credErr
+	}
+	return int(cred.Pid), nil
+}
+
+// isProcessOrDescendant 判断pid是否为ancestor本身或其子孙进程（应用可能由shell包装启动）
+func isProcessOrDescendant(pid, ancestor int) bool {
+	for i := 0; i < maxPeerAncestry && pid > 1; i++ {
+		if pid == ancestor {
+			return true
+		}
+		ppid, err := parentPID(pid)
+		if err != nil {
+			return false
+		}
+		pid = ppid
+	}
+	return false
+}
+
+// parentPID 从 /proc/<pid>/stat 读取父进程PID
+func parentPID(pid int) (int, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// 进程名可能包含空格和括号，从最后一个')'之后开始解析
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("invalid stat for pid %d", pid)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("invalid stat for pid %d", pid)
+	}
+	return strconv.Atoi(fields[1])
+}
+
+// ownedBySelf 判断文件是否属于当前有效用户
+func ownedBySelf(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Geteuid()
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package httpapi
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// peerPID 非Linux平台不支持 SO_PEERCRED
+func peerPID(conn net.Conn) (int, error) {
+	return 0, fmt.Errorf("peer credentials are only supported on linux")
+}
+
+// isProcessOrDescendant 非Linux平台仅支持精确匹配
+func isProcessOrDescendant(pid, ancestor int) bool {
+	return pid == ancestor
+}
+
+// ownedBySelf 非Linux平台只检查目录权限
+func ownedBySelf(info os.FileInfo) bool {
+	return true
+}
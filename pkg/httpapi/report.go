@@ -0,0 +1,164 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"brick-smart-template/pkg/auth"
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// peerPIDKey 请求上下文中保存Unix socket对端PID的键
+type peerPIDKey struct{}
+
+// DisablePublicReport 关闭公网端口上的应用侧接口（状态上报和命令通道），应用只能通过loopback或Unix socket上报
+func (server *Server) DisablePublicReport() {
+	server.reportDisabled = true
+}
+
+// publicReport 公网端口上的状态上报接口可通过配置关闭
+func (server *Server) publicReport(c *gin.Context) {
+	if server.reportDisabled {
+		server.abortWithError(c, models.NewAPIError(http.StatusNotFound, models.ErrorCodeNotFound, "status report is not available on this listener"))
+		return
+	}
+	c.Next()
+}
+
+// RunReport 启动仅提供应用侧接口（状态上报和命令通道）的明文HTTP服务器，供子进程通过loopback访问
+func (server *Server) RunReport(addr string) error {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	server.setupAppRoutes(router, server.authenticateApp, server.authorize(auth.RoleDevice))
+
+	server.logger.Infof("Starting status report server on %s", addr)
+	return router.Run(addr)
+}
+
+// RunReportSocket 在Unix socket上提供应用侧接口，按对端进程的PID认证
+// socket所在目录必须只有proxy自身可写，应用以其他用户运行时由 appmanager 把目录和socket授权给应用的组
+func (server *Server) RunReportSocket(path string) error {
+	if err := prepareSocketDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	// 清理上次运行残留的socket文件（目录私有，其他用户无法抢先创建）
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+	server.setupAppRoutes(router, server.authenticatePeer)
+
+	httpServer := &http.Server{
+		Handler: router,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			if pid, err := peerPID(conn); err == nil {
+				return context.WithValue(ctx, peerPIDKey{}, pid)
+			}
+			return ctx
+		},
+	}
+
+	server.logger.Infof("Starting status report socket on %s", path)
+	return httpServer.Serve(listener)
+}
+
+// prepareSocketDir 创建socket目录（0700），已存在的目录必须是proxy所有且其他用户不可写
+func prepareSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("report socket dir %s is not a directory", dir)
+	}
+	if info.Mode().Perm()&0022 != 0 || !ownedBySelf(info) {
+		return fmt.Errorf("report socket dir %s must be owned by the proxy user and not writable by others", dir)
+	}
+	return nil
+}
+
+// authenticatePeer 校验Unix socket对端是当前应用进程（或其子进程）
+func (server *Server) authenticatePeer(c *gin.Context) {
+	pid, _ := c.Request.Context().Value(peerPIDKey{}).(int)
+	appPID := server.manager.AppPID()
+	if pid == 0 || appPID == 0 || !isProcessOrDescendant(pid, appPID) {
+		server.logger.Warnf("Rejected status report from pid %d on report socket (app pid %d)", pid, appPID)
+		server.abortWithError(c, models.NewAPIError(http.StatusForbidden, models.ErrorCodeForbidden, "peer is not the app process").
+			WithDetail("reason", "peer_not_app"))
+		return
+	}
+
+	c.Set(identityKey, &auth.Identity{
+		Subject: server.manager.GetStatus().AppName,
+		Method:  auth.MethodPeerCred,
+		Role:    auth.RoleDevice,
+	})
+	c.Next()
+}
+
+// 状态增量上报支持的内容类型
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// patchStatus 增量上报内部状态：application/merge-patch+json（RFC 7386，application/json 按此处理）
+// 或 application/json-patch+json（RFC 6902），原子地应用到当前内部状态，返回新的版本号
+func (server *Server) patchStatus(c *gin.Context) {
+	var (
+		revision   uint64
+		violations []models.SchemaViolation
+		err        error
+	)
+	switch contentType := c.ContentType(); contentType {
+	case mergePatchContentType, "application/json", "":
+		var patch map[string]interface{}
+		if err := c.ShouldBindJSON(&patch); err != nil || patch == nil {
+			server.abortWithError(c, models.InvalidRequest("merge patch must be a JSON object"))
+			return
+		}
+		revision, violations, err = server.manager.MergePatchInternalStatus(patch)
+	case jsonPatchContentType:
+		var operations []models.JSONPatchOperation
+		if err := c.ShouldBindJSON(&operations); err != nil {
+			server.abortWithError(c, invalidRequest(err))
+			return
+		}
+		revision, violations, err = server.manager.JSONPatchInternalStatus(operations)
+	default:
+		c.Header("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+		server.abortWithError(c, models.NewAPIError(http.StatusUnsupportedMediaType, models.ErrorCodeUnsupportedMediaType,
+			fmt.Sprintf("unsupported content type %q", contentType)).
+			WithDetail("supported", []string{mergePatchContentType, jsonPatchContentType}))
+		return
+	}
+	if err != nil {
+		server.logger.Warnf("Rejected status patch: %v", err)
+		server.abortWithError(c, err)
+		return
+	}
+
+	c.Header(revisionHeader, strconv.FormatUint(revision, 10))
+	server.respond(c, http.StatusOK, models.ReportStatusResponse{Status: "patched", Revision: revision, Violations: violations})
+}
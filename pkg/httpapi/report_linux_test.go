@@ -0,0 +1,194 @@
+//go:build linux
+
+package httpapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"brick-smart-template/pkg/appmanager"
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// newRunningServer 创建应用已启动的测试服务器，应用为长时间运行的 sh 进程
+func newRunningServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	manager, err := appmanager.NewManager(logger, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// proxy 会在参数末尾补充 -id test，sh -c 把它当作 $0
+	if err := manager.ConfigureApp("test", models.AppInfo{Name: "sleeper", Command: "/bin/sh", Args: []string{"-c", "exec sleep 30"}, HealthCheckInterval: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.StartApp("test", "{}"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { manager.StopApp("test") })
+	return NewServer(manager, logger)
+}
+
+func TestIsProcessOrDescendant(t *testing.T) {
+	child := exec.Command("sleep", "30")
+	if err := child.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		child.Process.Kill()
+		child.Wait()
+	})
+
+	self := os.Getpid()
+	tests := []struct {
+		name          string
+		pid, ancestor int
+		want          bool
+	}{
+		{"same process", self, self, true},
+		{"child", child.Process.Pid, self, true},
+		{"parent", self, child.Process.Pid, false},
+		{"unrelated", os.Getppid(), self, false},
+		{"init", 1, self, false},
+	}
+	for _, tt := range tests {
+		if got := isProcessOrDescendant(tt.pid, tt.ancestor); got != tt.want {
+			t.Errorf("%s: isProcessOrDescendant(%d, %d) = %v, want %v", tt.name, tt.pid, tt.ancestor, got, tt.want)
+		}
+	}
+}
+
+func TestAuthenticatePeer(t *testing.T) {
+	server := newRunningServer(t)
+	appPID := server.manager.AppPID()
+	if appPID == 0 {
+		t.Fatal("app is not running")
+	}
+	router := gin.New()
+	server.setupAppRoutes(router, server.authenticatePeer)
+
+	tests := []struct {
+		name string
+		pid  int // 0 表示没有对端凭证
+		want int
+	}{
+		{"app process", appPID, http.StatusOK},
+		{"proxy itself", os.Getpid(), http.StatusForbidden},
+		{"no peer credentials", 0, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/v1/app/status/report", strings.NewReader(`{"data":{"mode":"eco"}}`))
+		r.Header.Set("Content-Type", "application/json")
+		if tt.pid != 0 {
+			r = r.WithContext(context.WithValue(r.Context(), peerPIDKey{}, tt.pid))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
+
+func TestReportSocket(t *testing.T) {
+	server := newRunningServer(t)
+	dir := filepath.Join(t.TempDir(), "report")
+	path := filepath.Join(dir, "report.sock")
+	go server.RunReportSocket(path)
+
+	var conn net.Conn
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if conn, err = net.Dial("unix", path); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// socket和目录只有proxy自身可以访问
+	for file, want := range map[string]os.FileMode{dir: 0o700, path: 0o600} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %o, want %o", file, got, want)
+		}
+	}
+
+	// 测试进程不是应用的子孙进程，上报被拒绝
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+	resp, err := client.Post("http://report/v1/app/status/report", "application/json", strings.NewReader(`{"data":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "peer_not_app") {
+		t.Errorf("report from non-descendant: status %d, body %s", resp.StatusCode, body)
+	}
+}
+
+func TestPrepareSocketDir(t *testing.T) {
+	base := t.TempDir()
+
+	created := filepath.Join(base, "created")
+	if err := prepareSocketDir(created); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0o700 {
+		t.Errorf("created dir mode = %o, want 700", got)
+	}
+
+	shared := filepath.Join(base, "shared")
+	if err := os.Mkdir(shared, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareSocketDir(shared); err == nil {
+		t.Errorf("world-writable dir was accepted")
+	}
+
+	file := filepath.Join(base, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareSocketDir(file); err == nil {
+		t.Errorf("regular file was accepted")
+	}
+
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(created, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareSocketDir(link); err == nil {
+		t.Errorf("symlink was accepted")
+	}
+}
@@ -1,30 +1,49 @@
 package httpapi
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"net/http"
+	"time"
 
 	"brick-smart-template/pkg/appmanager"
+	"brick-smart-template/pkg/auth"
+	"brick-smart-template/pkg/metrics"
 	"brick-smart-template/pkg/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
+// identityKey gin上下文中保存调用方身份的键
+const identityKey = "identity"
+
 // Server HTTP API服务器
 type Server struct {
-	router  *gin.Engine
-	manager *appmanager.Manager
-	logger  *logrus.Logger
+	router         *gin.Engine
+	manager        *appmanager.Manager
+	logger         *logrus.Logger
+	authenticator  auth.Authenticator // 为nil时不启用认证
+	reportDisabled bool               // 公网端口上关闭状态上报接口
+	metrics        *metrics.Metrics
+	idempotency    *idempotencyStore // Idempotency-Key对应的首次响应
+	legacy         *legacyRoutes     // 旧路径的弃用计划和使用计数
+	started        time.Time         // 服务器创建时间，用于生成ETag
 }
 
 // NewServer 创建新的HTTP服务器
 func NewServer(manager *appmanager.Manager, logger *logrus.Logger) *Server {
 	server := &Server{
-		router:  gin.Default(),
-		manager: manager,
-		logger:  logger,
+		router:      gin.Default(),
+		manager:     manager,
+		logger:      logger,
+		metrics:     metrics.New(manager),
+		idempotency: newIdempotencyStore(DefaultIdempotencyTTL),
+		legacy:      newLegacyRoutes(),
+		started:     time.Now(),
 	}
 
+	server.router.Use(server.metrics.Middleware())
 	server.setupRoutes()
 	return server
 }
@@ -34,20 +53,162 @@ func (server *Server) setupRoutes() {
 	// 健康检查
 	server.router.GET("/health", server.healthCheck)
 
+	// OpenAPI文档和查看页面
+	server.router.GET("/openapi.json", server.getOpenAPI)
+	server.router.GET("/docs", server.getAPIDocs)
+
+	// Prometheus指标
+	server.router.GET("/metrics", server.authenticate, server.authorize(auth.RoleViewer), gin.WrapH(server.metrics.Handler()))
+
+	// v1 API
+	v1Group := server.router.Group(apiV1Prefix, server.versioned)
+	server.setupAPIRoutes(v1Group)
+	v1Group.GET("/deprecations", server.authenticate, server.authorize(auth.RoleViewer), server.getDeprecatedRoutes)
+	v1Group.GET("/app/data/history", server.authenticate, server.authorize(auth.RoleViewer), server.getDataHistory)
+	v1Group.GET("/app/data/stats", server.authenticate, server.authorize(auth.RoleViewer), server.getDataStats)
+	// 应用输出可能包含未被脱敏的敏感信息，只向operator提供
+	v1Group.GET("/app/logs/stream", server.authenticate, server.authorize(auth.RoleOperator), server.streamLogs)
+	v1Group.GET("/alerts", server.authenticate, server.authorize(auth.RoleViewer), server.getAlerts)
+	v1Group.POST("/alerts/reload", server.authenticate, server.authorize(auth.RoleAdmin), server.reloadAlertRules)
+
+	// 未分版本的旧路径，保留为 /v1 的弃用别名
+	server.setupAPIRoutes(server.router.Group("", server.deprecated))
+
+	// 应用侧API：状态报告 (用于gRPC的替代) 和命令通道
+	server.setupAppRoutes(server.router, server.publicReport, server.authenticateApp, server.authorize(auth.RoleDevice))
+}
+
+// setupAPIRoutes 注册管理API
+func (server *Server) setupAPIRoutes(router gin.IRouter) {
+	// 异步生命周期操作
+	router.GET("/operations/:id", server.authenticate, server.authorize(auth.RoleViewer), server.getOperation)
+
 	// 应用管理API
-	appGroup := server.router.Group("/app")
+	appGroup := router.Group("/app", server.authenticate)
 	{
-		appGroup.POST("/configure", server.configureApp)
-		appGroup.POST("/start", server.startApp)
-		appGroup.POST("/restart", server.restartApp)
-		appGroup.POST("/stop", server.stopApp)
-		appGroup.GET("/status", server.getAppStatus)
-		appGroup.GET("/data", server.getInternalStatus)
-		appGroup.GET("/process", server.getProcessStatus)
+		// 只读接口
+		readGroup := appGroup.Group("", server.authorize(auth.RoleViewer))
+		readGroup.GET("/status", server.getAppStatus)
+		readGroup.GET("/data", server.getInternalStatus)
+		readGroup.GET("/process", server.getProcessStatus)
+		readGroup.GET("/history", server.getHistory)
+		readGroup.GET("/commands/:id", server.getCommand)
+		readGroup.GET("/stream", server.streamEvents)
+		readGroup.GET("/ws", server.serveWebSocket)
+
+		// 控制接口，变更类接口都支持 Idempotency-Key
+		controlGroup := appGroup.Group("", server.authorize(auth.RoleOperator), server.idempotent)
+		controlGroup.POST("/start", server.startApp)
+		controlGroup.POST("/restart", server.restartApp)
+		controlGroup.POST("/stop", server.stopApp)
+		controlGroup.POST("/command", server.enqueueCommand)
+
+		// 配置接口
+		adminGroup := appGroup.Group("", server.authorize(auth.RoleAdmin), server.idempotent)
+		adminGroup.POST("/configure", server.configureApp)
+	}
+}
+
+// setupAppRoutes 注册应用自身调用的接口，各监听器使用不同的认证方式，旧路径同样保留为弃用别名
+func (server *Server) setupAppRoutes(router gin.IRouter, handlers ...gin.HandlerFunc) {
+	for _, group := range []*gin.RouterGroup{
+		router.Group(apiV1Prefix, server.versioned).Group("/app", handlers...),
+		router.Group("", server.deprecated).Group("/app", handlers...),
+	} {
+		group.POST("/status/report", server.idempotent, server.reportStatus)
+		group.PATCH("/status/report", server.idempotent, server.patchStatus)
+		group.GET("/commands/stream", server.streamCommands)
+		group.POST("/commands/:id/result", server.idempotent, server.completeCommand)
+	}
+}
+
+// SetAuthenticator 设置管理API的认证器，nil表示不启用认证
+func (server *Server) SetAuthenticator(authenticator auth.Authenticator) {
+	server.authenticator = authenticator
+}
+
+// authenticate 认证管理API的调用方
+func (server *Server) authenticate(c *gin.Context) {
+	if server.authenticator == nil {
+		c.Next()
+		return
+	}
+
+	identity, err := server.authenticator.Authenticate(c.Request)
+	if err != nil {
+		server.logger.Warnf("Authentication failed for %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		c.Header("WWW-Authenticate", `Bearer realm="brick-proxy"`)
+		server.abortWithError(c, models.ErrUnauthorized)
+		return
 	}
 
-	// 状态报告API (用于gRPC的替代)
-	server.router.POST("/app/status/report", server.reportStatus)
+	c.Set(identityKey, identity)
+	c.Next()
+}
+
+// authenticateApp 认证应用的状态上报，使用启动时注入的 PROXY_APP_TOKEN
+func (server *Server) authenticateApp(c *gin.Context) {
+	if server.authenticator == nil {
+		c.Next()
+		return
+	}
+
+	if !server.manager.VerifyAppToken(auth.BearerToken(c.Request)) {
+		server.logger.Warnf("Rejected status report with invalid app token")
+		c.Header("WWW-Authenticate", `Bearer realm="brick-proxy-app"`)
+		server.abortWithError(c, models.ErrUnauthorized)
+		return
+	}
+
+	c.Set(identityKey, &auth.Identity{
+		Subject: server.manager.GetStatus().AppName,
+		Method:  auth.MethodAppToken,
+		Role:    auth.RoleDevice,
+	})
+	c.Next()
+}
+
+// authorize 要求调用方具备指定角色
+func (server *Server) authorize(required string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if server.authenticator == nil {
+			c.Next()
+			return
+		}
+
+		identity := identityFrom(c)
+		if identity == nil || !auth.Allows(identity.Role, required) {
+			role := ""
+			if identity != nil {
+				role = identity.Role
+			}
+			server.logger.Warnf("Forbidden %s %s for %s (role %q, requires %q)", c.Request.Method, c.Request.URL.Path, actorFrom(c), role, required)
+			server.abortWithError(c, models.NewAPIError(http.StatusForbidden, models.ErrorCodeForbidden, "insufficient role").
+				WithDetail("reason", "insufficient_role").
+				WithDetail("role", role).
+				WithDetail("required_role", required))
+			return
+		}
+		c.Next()
+	}
+}
+
+// identityFrom 获取认证后的调用方身份，未启用认证时返回nil
+func identityFrom(c *gin.Context) *auth.Identity {
+	if value, ok := c.Get(identityKey); ok {
+		if identity, ok := value.(*auth.Identity); ok {
+			return identity
+		}
+	}
+	return nil
+}
+
+// actorFrom 获取记录到生命周期历史中的调用方名称
+func actorFrom(c *gin.Context) string {
+	if identity := identityFrom(c); identity != nil {
+		return identity.Subject
+	}
+	return "anonymous"
 }
 
 // healthCheck 健康检查
@@ -65,25 +226,30 @@ func (server *Server) healthCheck(c *gin.Context) {
 
 // configureApp 配置应用
 func (server *Server) configureApp(c *gin.Context) {
-	var request models.ConfigureAppRequest
+	// 保留 app_info 的原始字节用于签名校验
+	var request struct {
+		AppInfo   json.RawMessage `json:"app_info"`
+		Signature string          `json:"signature"`
+	}
 	if err := c.ShouldBindJSON(&request); err != nil {
 		server.logger.Errorf("Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		server.abortWithError(c, invalidRequest(err))
 		return
 	}
 
-	if err := server.manager.ConfigureApp(request.AppInfo); err != nil {
+	appInfo, err := server.manager.ConfigureAppPayload(actorFrom(c), request.AppInfo, request.Signature)
+	if err != nil {
 		server.logger.Errorf("Failed to configure app: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		server.abortWithError(c, err)
 		return
 	}
 
 	response := models.ConfigureAppResponse{
 		Status:  "configured",
-		AppName: request.AppInfo.Name,
+		AppName: appInfo.Name,
 	}
 
-	c.JSON(http.StatusOK, response)
+	server.respond(c, http.StatusOK, response)
 }
 
 // startApp 启动应用
@@ -91,61 +257,46 @@ func (server *Server) startApp(c *gin.Context) {
 	var request models.StartAppRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		server.logger.Errorf("Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		server.abortWithError(c, invalidRequest(err))
 		return
 	}
 
 	// 校验 app_name 和 id
-	proxyID := server.manager.ProxyID()
-	proxyAppName := ""
-	if server.manager.GetStatus() != nil {
-		proxyAppName = server.manager.GetStatus().AppName
-	}
-	if request.ID != "" && request.ID != proxyID {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id mismatch: expected " + proxyID})
-		return
-	}
-	if request.AppName != "" && proxyAppName != "" && request.AppName != proxyAppName {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "app_name mismatch: expected " + proxyAppName})
-		return
-	}
-
-	response, err := server.manager.StartApp(request.Profile)
-	if err != nil {
-		server.logger.Errorf("Failed to start app: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := server.manager.CheckTarget(request.ID, request.AppName); err != nil {
+		server.abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	// gin.Context 在请求结束后会被复用，后台执行前先取出actor
+	actor := actorFrom(c)
+	server.runOperation(c, models.OperationStart, func() (interface{}, error) {
+		return server.manager.StartApp(actor, request.Profile)
+	})
 }
 
 // restartApp 重启应用
 func (server *Server) restartApp(c *gin.Context) {
-	response, err := server.manager.RestartApp()
-	if err != nil {
-		server.logger.Errorf("Failed to restart app: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, response)
+	actor := actorFrom(c)
+	server.runOperation(c, models.OperationRestart, func() (interface{}, error) {
+		return server.manager.RestartApp(actor)
+	})
 }
 
 // stopApp 停止应用
 func (server *Server) stopApp(c *gin.Context) {
-	response, err := server.manager.StopApp()
-	if err != nil {
-		server.logger.Errorf("Failed to stop app: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, response)
+	actor := actorFrom(c)
+	server.runOperation(c, models.OperationStop, func() (interface{}, error) {
+		return server.manager.StopApp(actor)
+	})
 }
 
 // getAppStatus 获取应用状态
 func (server *Server) getAppStatus(c *gin.Context) {
 	status := server.manager.GetStatus()
+	if isV1(c) {
+		server.respond(c, http.StatusOK, status)
+		return
+	}
 	processID := server.manager.ProxyID()
 	c.JSON(http.StatusOK, gin.H{
 		"process_id": processID,
@@ -153,16 +304,14 @@ func (server *Server) getAppStatus(c *gin.Context) {
 	})
 }
 
-// getInternalStatus 获取应用内部状态
-func (server *Server) getInternalStatus(c *gin.Context) {
-	internalStatus := server.manager.GetInternalStatus()
-	c.JSON(http.StatusOK, internalStatus)
-}
-
 // getProcessStatus 获取合并后的状态和数据
 func (server *Server) getProcessStatus(c *gin.Context) {
     status := server.manager.GetStatus()
     data := server.manager.GetInternalStatus()
+    if isV1(c) {
+        server.respond(c, http.StatusOK, models.ProcessView{Status: status, Data: data})
+        return
+    }
     processID := server.manager.ProxyID()
     c.JSON(http.StatusOK, gin.H{
         "app_name": status.AppName,
@@ -172,30 +321,57 @@ func (server *Server) getProcessStatus(c *gin.Context) {
     })
 }
 
-// reportStatus 报告状态 (gRPC的HTTP替代)
-func (server *Server) reportStatus(c *gin.Context) {
-	var request struct {
-		Status string                 `json:"status"`
-		Data   map[string]interface{} `json:"data"`
+// getHistory 获取应用生命周期历史
+func (server *Server) getHistory(c *gin.Context) {
+	if isV1(c) {
+		server.respond(c, http.StatusOK, server.manager.History())
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{
+		"process_id": server.manager.ProxyID(),
+		"history":    server.manager.History(),
+	})
+}
 
+// reportStatus 报告状态 (gRPC的HTTP替代)
+func (server *Server) reportStatus(c *gin.Context) {
+	var request models.ReportStatusRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		server.logger.Errorf("Invalid status report: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		server.abortWithError(c, invalidRequest(err))
+		return
+	}
+
+	report, err := request.StatusReport()
+	if err != nil {
+		server.abortWithError(c, err)
+		return
+	}
+	revision, violations, err := server.manager.ReportStatus(report)
+	if err != nil {
+		server.abortWithError(c, err)
 		return
 	}
 
-	// 只更新内部状态
-	// 同时更新内部状态
-	server.manager.UpdateInternalStatus(request.Data)
-	
 	server.logger.Infof("Received status report: %s", request.Status)
 
-	c.JSON(http.StatusOK, gin.H{"status": "received"})
+	server.respond(c, http.StatusOK, models.ReportStatusResponse{Status: "received", Revision: revision, Violations: violations})
 }
 
 // Run 启动HTTP服务器
 func (server *Server) Run(addr string) error {
 	server.logger.Infof("Starting HTTP server on %s", addr)
 	return server.router.Run(addr)
+}
+
+// RunTLS 启动HTTPS服务器
+func (server *Server) RunTLS(addr string, tlsConfig *tls.Config) error {
+	server.logger.Infof("Starting HTTPS server on %s", addr)
+	httpServer := &http.Server{
+		Addr:      addr,
+		Handler:   server.router,
+		TLSConfig: tlsConfig,
+	}
+	// 证书由 tlsConfig 提供
+	return httpServer.ListenAndServeTLS("", "")
 } 
\ No newline at end of file
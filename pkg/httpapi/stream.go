@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brick-smart-template/pkg/appmanager"
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// streamEvents 以SSE推送生命周期事件、状态上报和告警，支持按 Last-Event-ID 续传
+func (server *Server) streamEvents(c *gin.Context) {
+	server.serveStream(c, server.manager.Subscribe)
+}
+
+// streamLogs 以SSE推送应用输出，与 /app/stream 使用独立的续传缓冲
+func (server *Server) streamLogs(c *gin.Context) {
+	server.serveStream(c, server.manager.SubscribeLogs)
+}
+
+// serveStream 以SSE推送订阅的事件，支持按 Last-Event-ID 续传
+func (server *Server) serveStream(c *gin.Context, subscribe func(lastID uint64) (*appmanager.Subscription, []models.StreamEvent, bool)) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			server.abortWithError(c, models.InvalidRequest("invalid Last-Event-ID"))
+			return
+		}
+		lastID = id
+	}
+
+	subscription, replay, complete := subscribe(lastID)
+	defer subscription.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// 缓冲中已没有断线期间的全部事件，通知客户端重新获取完整状态
+	if !complete {
+		c.Render(-1, sse.Event{Event: "reset", Data: gin.H{"last_event_id": lastID}})
+	}
+	for _, event := range replay {
+		server.writeStreamEvent(c, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// 消费过慢被断开，客户端按 Last-Event-ID 重连补发
+				server.logger.Warnf("Closing slow event stream client %s", c.ClientIP())
+				return
+			}
+			server.writeStreamEvent(c, event)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeStreamEvent 写入一个SSE事件，事件名为事件类型
+func (server *Server) writeStreamEvent(c *gin.Context, event models.StreamEvent) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
@@ -0,0 +1,211 @@
+package httpapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// certCheckInterval 检查证书文件是否轮换的最小间隔
+const certCheckInterval = 10 * time.Second
+
+// TLSConfig 配置文件中的 http.tls 段
+type TLSConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	CertFile          string `mapstructure:"cert_file"`
+	KeyFile           string `mapstructure:"key_file"`
+	MinVersion        string `mapstructure:"min_version"`         // "1.2"（默认）或 "1.3"
+	ClientCAFile      string `mapstructure:"client_ca_file"`      // 校验客户端证书的CA
+	RequireClientCert bool   `mapstructure:"require_client_cert"` // 为false时客户端证书可选
+	SelfSigned        bool   `mapstructure:"self_signed"`         // 证书文件不存在时生成自签名证书（仅用于实验环境）
+}
+
+// LoadTLSConfig 从viper配置构建TLS配置，未启用TLS时返回nil
+func LoadTLSConfig(logger *logrus.Logger) (*tls.Config, error) {
+	var config TLSConfig
+	if err := viper.UnmarshalKey("http.tls", &config); err != nil {
+		return nil, fmt.Errorf("invalid tls config: %v", err)
+	}
+	if !config.Enabled {
+		return nil, nil
+	}
+	return NewTLSConfig(config, logger)
+}
+
+// NewTLSConfig 根据配置构建TLS配置
+func NewTLSConfig(config TLSConfig, logger *logrus.Logger) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch config.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported tls min_version %q", config.MinVersion)
+	}
+
+	if config.SelfSigned && !fileExists(config.CertFile) {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		fingerprint := sha256.Sum256(cert.Certificate[0])
+		logger.Warnf("Using self-signed TLS certificate (sha256 %s), not for production", hex.EncodeToString(fingerprint[:]))
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	} else {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, fmt.Errorf("tls requires cert_file and key_file")
+		}
+		reloader, err := newCertReloader(config.CertFile, config.KeyFile, logger)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetCertificate = reloader.GetCertificate
+	}
+
+	if config.ClientCAFile != "" {
+		data, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if config.RequireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if config.RequireClientCert {
+		return nil, fmt.Errorf("tls require_client_cert needs client_ca_file")
+	}
+
+	return tlsConfig, nil
+}
+
+// certReloader 证书文件轮换后自动重新加载
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *logrus.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// newCertReloader 加载证书并创建重新加载器
+func newCertReloader(certFile, keyFile string, logger *logrus.Logger) (*certReloader, error) {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// GetCertificate 供 tls.Config 使用，文件有更新时重新加载
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= certCheckInterval {
+		r.lastCheck = time.Now()
+		if modTime, err := r.latestModTime(); err == nil && modTime.After(r.modTime) {
+			// 加载失败时继续使用旧证书（可能正处于写入过程中）
+			if err := r.load(); err != nil {
+				r.logger.Errorf("Failed to reload TLS certificate: %v", err)
+			} else {
+				r.logger.Infof("Reloaded TLS certificate from %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// load 读取证书和私钥
+func (r *certReloader) load() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("failed to stat tls certificate: %v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls certificate: %v", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// latestModTime 证书和私钥文件中较新的修改时间
+func (r *certReloader) latestModTime() (time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
+
+// selfSignedCertificate 生成覆盖localhost和本机主机名的自签名证书
+func selfSignedCertificate() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[len(hosts)-1], Organization: []string{"brick-proxy self-signed"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     hosts,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %v", err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// fileExists 判断文件是否存在
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
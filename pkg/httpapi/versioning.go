@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// apiV1Prefix 当前版本接口的路径前缀
+	apiV1Prefix = "/v1"
+	// apiVersionKey gin上下文中标记请求来自 /v1 路由的键
+	apiVersionKey = "api_version"
+)
+
+// legacyRoutes 未分版本的旧路径：弃用计划和按路由的使用计数
+type legacyRoutes struct {
+	mu           sync.Mutex
+	deprecatedAt time.Time
+	sunset       time.Time
+	usage        map[string]*models.DeprecatedRoute // 按 "方法 路由" 索引
+}
+
+// newLegacyRoutes 创建旧路径的使用计数
+func newLegacyRoutes() *legacyRoutes {
+	return &legacyRoutes{usage: make(map[string]*models.DeprecatedRoute)}
+}
+
+// SetLegacyRouteSchedule 设置旧路径的弃用时间和下线时间，通过 Deprecation 和 Sunset 响应头告知调用方
+func (server *Server) SetLegacyRouteSchedule(deprecatedAt, sunset time.Time) {
+	server.legacy.mu.Lock()
+	defer server.legacy.mu.Unlock()
+	server.legacy.deprecatedAt = deprecatedAt
+	server.legacy.sunset = sunset
+}
+
+// versioned 标记 /v1 路由，成功响应统一包装为 Envelope
+func (server *Server) versioned(c *gin.Context) {
+	c.Set(apiVersionKey, "v1")
+	c.Next()
+}
+
+// deprecated 旧路径是 /v1 接口的别名：附带 Deprecation、Sunset 和 successor-version 链接，并记录使用次数
+func (server *Server) deprecated(c *gin.Context) {
+	route := c.FullPath()
+	now := time.Now()
+
+	legacy := server.legacy
+	legacy.mu.Lock()
+	key := c.Request.Method + " " + route
+	usage, ok := legacy.usage[key]
+	if !ok {
+		usage = &models.DeprecatedRoute{Method: c.Request.Method, Route: route, Successor: apiV1Prefix + route}
+		legacy.usage[key] = usage
+	}
+	usage.Count++
+	usage.LastUsed = &now
+	deprecatedAt, sunset := legacy.deprecatedAt, legacy.sunset
+	legacy.mu.Unlock()
+	server.metrics.DeprecatedRequest(c.Request.Method, route)
+
+	if !deprecatedAt.IsZero() {
+		c.Header("Deprecation", fmt.Sprintf("@%d", deprecatedAt.Unix()))
+	} else {
+		c.Header("Deprecation", "true")
+	}
+	if !sunset.IsZero() {
+		c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, apiV1Prefix+c.Request.URL.Path))
+	c.Next()
+}
+
+// isV1 请求是否来自 /v1 路由
+func isV1(c *gin.Context) bool {
+	return c.GetString(apiVersionKey) == "v1"
+}
+
+// respond 返回成功响应，/v1 路由包装为 Envelope，旧路径保持原有结构
+func (server *Server) respond(c *gin.Context, status int, data interface{}) {
+	if isV1(c) {
+		c.JSON(status, models.Envelope{Data: data, Meta: &models.EnvelopeMeta{ProcessID: server.manager.ProxyID()}})
+		return
+	}
+	c.JSON(status, data)
+}
+
+// getDeprecatedRoutes 列出所有旧路径及其使用次数，长期未使用的旧路径可以移除
+func (server *Server) getDeprecatedRoutes(c *gin.Context) {
+	registered := make(map[string]bool)
+	for _, route := range server.router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	routes := []models.DeprecatedRoute{}
+	server.legacy.mu.Lock()
+	for _, route := range server.router.Routes() {
+		if strings.HasPrefix(route.Path, apiV1Prefix+"/") || !registered[route.Method+" "+apiV1Prefix+route.Path] {
+			continue
+		}
+		entry := models.DeprecatedRoute{Method: route.Method, Route: route.Path, Successor: apiV1Prefix + route.Path}
+		if usage, ok := server.legacy.usage[route.Method+" "+route.Path]; ok {
+			entry = *usage
+		}
+		routes = append(routes, entry)
+	}
+	server.legacy.mu.Unlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Route != routes[j].Route {
+			return routes[i].Route < routes[j].Route
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	server.respond(c, http.StatusOK, routes)
+}
@@ -0,0 +1,400 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/appmanager"
+	"brick-smart-template/pkg/auth"
+	"brick-smart-template/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket订阅主题
+const (
+	topicStatus = "status" // 应用状态快照，订阅时和每次生命周期变化后推送
+	topicData   = "data"   // 状态上报
+	topicLogs   = "logs"   // 应用输出，需要operator角色
+	topicEvents = "events" // 生命周期事件
+)
+
+const (
+	// wsSendBuffer 每个连接待发送消息的缓冲，事件写满时断开连接
+	wsSendBuffer = 64
+	// wsMaxControls 每个连接同时执行的控制请求上限，超出的请求直接返回错误
+	wsMaxControls = wsSendBuffer
+	// wsWriteTimeout 单条消息的写超时
+	wsWriteTimeout = 10 * time.Second
+	// wsPingInterval 发送ping的间隔，超过 wsPongTimeout 未收到pong视为断开
+	wsPingInterval = 30 * time.Second
+	wsPongTimeout  = 60 * time.Second
+)
+
+// upgrader 默认只接受同源的WebSocket连接
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// wsRequest 客户端发送的消息
+type wsRequest struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"` // subscribe、unsubscribe、start、stop、restart、command
+	Topics []string        `json:"topics,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// wsResponse 对客户端请求的响应，ID与请求相同
+type wsResponse struct {
+	ID     string           `json:"id"`
+	Type   string           `json:"type"` // 固定为 response
+	OK     bool             `json:"ok"`
+	Result interface{}      `json:"result,omitempty"`
+	Error  *models.APIError `json:"error,omitempty"`
+}
+
+// wsEvent 推送给订阅者的事件
+type wsEvent struct {
+	Type    string      `json:"type"` // 固定为 event
+	Topic   string      `json:"topic"`
+	EventID uint64      `json:"event_id,omitempty"` // 对应 /app/stream 的事件id，状态快照没有id
+	Time    time.Time   `json:"time"`
+	Data    interface{} `json:"data"`
+}
+
+// wsConn 一个WebSocket连接
+type wsConn struct {
+	server   *Server
+	conn     *websocket.Conn
+	identity *auth.Identity
+	actor    string
+	send     chan interface{}
+	done     chan struct{}
+	controls chan struct{} // 正在执行的控制请求，容量为 wsMaxControls
+
+	closeOnce sync.Once
+	logsOnce  sync.Once // 首次订阅 logs 时订阅应用输出
+	mu        sync.Mutex
+	topics    map[string]bool
+}
+
+// serveWebSocket 在一个连接上提供订阅和控制，请求和响应通过id对应
+func (server *Server) serveWebSocket(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade已经写入了错误响应
+		server.logger.Warnf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	ws := &wsConn{
+		server:   server,
+		conn:     conn,
+		identity: identityFrom(c),
+		actor:    actorFrom(c),
+		send:     make(chan interface{}, wsSendBuffer),
+		done:     make(chan struct{}),
+		controls: make(chan struct{}, wsMaxControls),
+		topics:   make(map[string]bool),
+	}
+	subscription, _, _ := server.manager.Subscribe(0)
+	defer subscription.Close()
+
+	go ws.writeLoop()
+	go ws.forwardEvents(subscription)
+	ws.readLoop()
+}
+
+// readLoop 读取客户端请求，直到连接关闭
+func (ws *wsConn) readLoop() {
+	defer ws.close(websocket.CloseNormalClosure, "")
+
+	ws.conn.SetReadLimit(64 * 1024)
+	ws.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	ws.conn.SetPongHandler(func(string) error {
+		return ws.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, message, err := ws.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var request wsRequest
+		if err := json.Unmarshal(message, &request); err != nil {
+			ws.respond(wsResponse{Error: models.InvalidRequest("invalid message: " + err.Error())})
+			continue
+		}
+
+		switch request.Type {
+		case "subscribe", "unsubscribe":
+			response := ws.subscribe(request)
+			ws.respond(response)
+			if response.OK && request.Type == "subscribe" {
+				ws.pushSnapshots(request.Topics)
+			}
+		default:
+			// 控制操作可能耗时较长（如停止应用），并发执行，响应按完成顺序返回
+			select {
+			case ws.controls <- struct{}{}:
+				go func() {
+					defer func() { <-ws.controls }()
+					ws.respond(ws.control(request))
+				}()
+			default:
+				ws.respond(wsResponse{ID: request.ID, Error: models.NewAPIError(http.StatusTooManyRequests, models.ErrorCodeTooManyRequests,
+					"too many control requests in progress").WithDetail("limit", wsMaxControls)})
+			}
+		}
+	}
+}
+
+// subscribe 订阅或取消订阅主题
+func (ws *wsConn) subscribe(request wsRequest) wsResponse {
+	for _, topic := range request.Topics {
+		switch topic {
+		case topicStatus, topicData, topicLogs, topicEvents:
+		default:
+			return wsResponse{ID: request.ID, Error: models.InvalidRequest("unknown topic " + topic)}
+		}
+	}
+
+	subscribe := request.Type == "subscribe"
+	for _, topic := range request.Topics {
+		if topic == topicLogs && subscribe {
+			// 应用输出可能包含未被脱敏的敏感信息
+			if !ws.allows(auth.RoleOperator) {
+				return wsResponse{ID: request.ID, Error: insufficientRole(auth.RoleOperator)}
+			}
+			ws.logsOnce.Do(func() {
+				subscription, _, _ := ws.server.manager.SubscribeLogs(0)
+				go ws.forwardLogs(subscription)
+			})
+		}
+	}
+
+	ws.mu.Lock()
+	for _, topic := range request.Topics {
+		ws.topics[topic] = subscribe
+	}
+	ws.mu.Unlock()
+	return wsResponse{ID: request.ID, OK: true, Result: gin.H{"topics": ws.subscribedTopics()}}
+}
+
+// pushSnapshots 订阅 status 和 data 后立即推送当前快照
+func (ws *wsConn) pushSnapshots(topics []string) {
+	for _, topic := range topics {
+		switch topic {
+		case topicStatus:
+			ws.push(topicStatus, 0, ws.server.manager.GetStatus())
+		case topicData:
+			ws.push(topicData, 0, ws.server.manager.GetInternalStatus())
+		}
+	}
+}
+
+// control 执行控制操作，要求operator角色
+func (ws *wsConn) control(request wsRequest) wsResponse {
+	response := wsResponse{ID: request.ID}
+	if !ws.allows(auth.RoleOperator) {
+		response.Error = insufficientRole(auth.RoleOperator)
+		return response
+	}
+
+	manager := ws.server.manager
+	var (
+		result interface{}
+		err    error
+	)
+	switch request.Type {
+	case "start":
+		var params models.StartAppRequest
+		if err := decodeParams(request.Params, &params); err != nil {
+			response.Error = models.InvalidRequest(err.Error())
+			return response
+		}
+		if params.Profile == "" {
+			params.Profile = "{}"
+		}
+		if err = manager.CheckTarget(params.ID, params.AppName); err == nil {
+			result, err = manager.StartApp(ws.actor, params.Profile)
+		}
+	case "stop":
+		result, err = manager.StopApp(ws.actor)
+	case "restart":
+		result, err = manager.RestartApp(ws.actor)
+	case "command":
+		var params models.CommandRequest
+		if err := decodeParams(request.Params, &params); err != nil {
+			response.Error = models.InvalidRequest(err.Error())
+			return response
+		}
+		result, err = manager.EnqueueCommand(ws.actor, params)
+	default:
+		response.Error = models.InvalidRequest("unknown request type " + request.Type)
+		return response
+	}
+
+	if err != nil {
+		ws.server.logger.Errorf("WebSocket %s failed: %v", request.Type, err)
+		response.Error = models.AsAPIError(err)
+		return response
+	}
+	response.OK = true
+	response.Result = result
+	return response
+}
+
+// forwardEvents 将事件推送给订阅了对应主题的客户端
+func (ws *wsConn) forwardEvents(subscription *appmanager.Subscription) {
+	for {
+		select {
+		case <-ws.done:
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// 事件订阅因消费过慢被断开
+				ws.close(websocket.ClosePolicyViolation, "slow consumer")
+				return
+			}
+			switch event.Type {
+			case models.StreamEventLifecycle:
+				if ws.subscribed(topicEvents) {
+					ws.push(topicEvents, event.ID, event.Data)
+				}
+				if ws.subscribed(topicStatus) {
+					ws.push(topicStatus, 0, ws.server.manager.GetStatus())
+				}
+			case models.StreamEventStatus:
+				if ws.subscribed(topicData) {
+					ws.push(topicData, event.ID, event.Data)
+				}
+			}
+		}
+	}
+}
+
+// forwardLogs 将应用输出推送给订阅了 logs 的客户端，event_id 为 /app/logs/stream 的事件id
+func (ws *wsConn) forwardLogs(subscription *appmanager.Subscription) {
+	defer subscription.Close()
+
+	for {
+		select {
+		case <-ws.done:
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				ws.close(websocket.ClosePolicyViolation, "slow consumer")
+				return
+			}
+			if ws.subscribed(topicLogs) {
+				ws.push(topicLogs, event.ID, event.Data)
+			}
+		}
+	}
+}
+
+// allows 连接的调用方是否满足要求的角色，未启用认证时总是满足
+func (ws *wsConn) allows(required string) bool {
+	if ws.server.authenticator == nil {
+		return true
+	}
+	return ws.identity != nil && auth.Allows(ws.identity.Role, required)
+}
+
+// insufficientRole 角色不足的错误
+func insufficientRole(required string) *models.APIError {
+	return models.NewAPIError(http.StatusForbidden, models.ErrorCodeForbidden, "insufficient role").
+		WithDetail("reason", "insufficient_role").
+		WithDetail("required_role", required)
+}
+
+// respond 发送响应，等待发送缓冲有空位
+func (ws *wsConn) respond(response wsResponse) {
+	response.Type = "response"
+	select {
+	case ws.send <- response:
+	case <-ws.done:
+	}
+}
+
+// push 推送事件，发送缓冲已满时断开连接（客户端消费过慢）
+func (ws *wsConn) push(topic string, eventID uint64, data interface{}) {
+	event := wsEvent{Type: "event", Topic: topic, EventID: eventID, Time: time.Now(), Data: data}
+	select {
+	case ws.send <- event:
+	case <-ws.done:
+	default:
+		ws.server.logger.Warnf("Closing slow WebSocket client %s", ws.conn.RemoteAddr())
+		ws.close(websocket.ClosePolicyViolation, "slow consumer")
+	}
+}
+
+// subscribed 是否订阅了主题
+func (ws *wsConn) subscribed(topic string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.topics[topic]
+}
+
+// subscribedTopics 当前订阅的主题
+func (ws *wsConn) subscribedTopics() []string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	topics := []string{}
+	for _, topic := range []string{topicStatus, topicData, topicLogs, topicEvents} {
+		if ws.topics[topic] {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// writeLoop 串行写出消息并定期发送ping
+func (ws *wsConn) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ws.done:
+			return
+		case message := <-ws.send:
+			ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := ws.conn.WriteJSON(message); err != nil {
+				ws.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping.C:
+			if err := ws.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				ws.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+// close 发送关闭帧并关闭连接，可重复调用
+func (ws *wsConn) close(code int, reason string) {
+	ws.closeOnce.Do(func() {
+		close(ws.done)
+		if code != websocket.CloseAbnormalClosure {
+			message := websocket.FormatCloseMessage(code, reason)
+			ws.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		}
+		ws.conn.Close()
+	})
+}
+
+// decodeParams 解析请求参数，参数为空时保留零值
+func decodeParams(raw json.RawMessage, value interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, value)
+}
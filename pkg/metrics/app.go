@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"brick-smart-template/pkg/appmanager"
+	"brick-smart-template/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// appStatuses 应用状态指标中列出的全部状态
+var appStatuses = []string{
+	"ready",
+	string(models.AppStatusIdle),
+	string(models.AppStatusStarting),
+	string(models.AppStatusRunning),
+	string(models.AppStatusStopping),
+	string(models.AppStatusStopped),
+	string(models.AppStatusError),
+}
+
+var (
+	proxyUptimeDesc = prometheus.NewDesc("brick_proxy_uptime_seconds",
+		"Seconds since the proxy started.", nil, nil)
+	appStatusDesc = prometheus.NewDesc("brick_app_status",
+		"Current app status, 1 for the active status.", []string{"app", "status"}, nil)
+	appRestartsDesc = prometheus.NewDesc("brick_app_restarts_total",
+		"Number of app restarts.", []string{"app"}, nil)
+	appUptimeDesc = prometheus.NewDesc("brick_app_uptime_seconds",
+		"Seconds since the current app process started, 0 when not running.", []string{"app"}, nil)
+	lastReportAgeDesc = prometheus.NewDesc("brick_app_last_report_age_seconds",
+		"Seconds since the last accepted status report.", []string{"app"}, nil)
+	appDataDesc = prometheus.NewDesc("brick_app_data",
+		"Numeric field from the latest app status report.", []string{"app", "field"}, nil)
+)
+
+// appCollector 采集时读取应用管理器的当前状态
+type appCollector struct {
+	manager *appmanager.Manager
+	started time.Time
+}
+
+// newAppCollector 创建应用状态采集器
+func newAppCollector(manager *appmanager.Manager) *appCollector {
+	return &appCollector{manager: manager, started: time.Now()}
+}
+
+// Describe 实现 prometheus.Collector
+// 内部状态字段的指标名由manifest决定，因此不预先声明（unchecked collector）
+func (collector *appCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect 实现 prometheus.Collector
+func (collector *appCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	status := collector.manager.GetStatus()
+	app := status.AppName
+
+	ch <- prometheus.MustNewConstMetric(proxyUptimeDesc, prometheus.GaugeValue, now.Sub(collector.started).Seconds())
+	for _, name := range appStatuses {
+		value := 0.0
+		if name == status.Status {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(appStatusDesc, prometheus.GaugeValue, value, app, name)
+	}
+	ch <- prometheus.MustNewConstMetric(appRestartsDesc, prometheus.CounterValue, float64(status.RestartCount), app)
+
+	uptime := 0.0
+	if status.PID != nil && status.StartTime != nil {
+		uptime = now.Sub(*status.StartTime).Seconds()
+	}
+	ch <- prometheus.MustNewConstMetric(appUptimeDesc, prometheus.GaugeValue, uptime, app)
+
+	if lastReport := collector.manager.LastReportTime(); !lastReport.IsZero() {
+		ch <- prometheus.MustNewConstMetric(lastReportAgeDesc, prometheus.GaugeValue, now.Sub(lastReport).Seconds(), app)
+	}
+
+	collector.collectData(ch, app)
+}
+
+// collectData 导出内部状态中的数值字段
+// manifest配置了字段映射时只导出映射中的字段，否则导出所有顶层数值字段
+func (collector *appCollector) collectData(ch chan<- prometheus.Metric, app string) {
+	data := collector.manager.GetInternalStatus()
+	if data == nil {
+		return
+	}
+
+	config := collector.manager.MetricsConfig()
+	if config == nil || len(config.Fields) == 0 {
+		for field, raw := range data {
+			if value, ok := numericValue(raw); ok {
+				ch <- prometheus.MustNewConstMetric(appDataDesc, prometheus.GaugeValue, value, app, field)
+			}
+		}
+		return
+	}
+
+	for _, field := range config.Fields {
+		value, ok := numericValue(lookupField(data, field.Field))
+		if !ok {
+			continue
+		}
+		if field.Name == "" {
+			ch <- prometheus.MustNewConstMetric(appDataDesc, prometheus.GaugeValue, value, app, field.Field)
+			continue
+		}
+		help := field.Help
+		if help == "" {
+			help = "App status field " + field.Field + "."
+		}
+		desc := prometheus.NewDesc(field.Name, help, []string{"app"}, nil)
+		// 指标名无效时 NewConstMetric 返回错误，跳过该字段
+		if metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, value, app); err == nil {
+			ch <- metric
+		}
+	}
+}
+
+// lookupField 按 "." 分隔的路径读取嵌套字段
+func lookupField(data map[string]interface{}, path string) interface{} {
+	var current interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[key]
+	}
+	return current
+}
+
+// numericValue 将上报的值转换为指标值，非数值（包括布尔值和字符串）返回false
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
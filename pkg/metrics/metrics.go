@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"brick-smart-template/pkg/appmanager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics proxy的Prometheus指标
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	deprecated      *prometheus.CounterVec
+}
+
+// New 创建指标注册表，包含HTTP请求指标和应用状态指标
+func New(manager *appmanager.Manager) *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "brick_http_requests_total",
+			Help: "HTTP requests handled by the proxy, by route and status code.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "brick_http_request_duration_seconds",
+			Help:    "HTTP request latency by route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		deprecated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "brick_http_deprecated_requests_total",
+			Help: "Requests to deprecated unversioned routes, by route.",
+		}, []string{"method", "route"}),
+	}
+
+	metrics.registry.MustRegister(
+		metrics.requests,
+		metrics.requestDuration,
+		metrics.deprecated,
+		newAppCollector(manager),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return metrics
+}
+
+// Middleware 记录HTTP请求数量和耗时，按路由模板（如 /app/commands/:id）区分
+func (metrics *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.requests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.requestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// DeprecatedRequest 记录一次对已弃用路径的请求
+func (metrics *Metrics) DeprecatedRequest(method, route string) {
+	metrics.deprecated.WithLabelValues(method, route).Inc()
+}
+
+// Handler 以Prometheus文本格式输出指标
+func (metrics *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+)
+
+// API错误码，客户端按错误码而不是错误信息判断错误类型
+const (
+	ErrorCodeInvalidRequest       = "invalid_request"        // 请求体或参数无效
+	ErrorCodeInvalidAppInfo       = "invalid_app_info"       // 应用配置无效
+	ErrorCodeInvalidProfile       = "invalid_profile"        // profile不是有效的JSON或无法渲染模板
+	ErrorCodeIDMismatch           = "id_mismatch"            // 请求中的id与proxy id不一致
+	ErrorCodeAppNameMismatch      = "app_name_mismatch"      // 请求中的app_name与已配置的应用不一致
+	ErrorCodeNotConfigured        = "not_configured"         // 应用尚未配置
+	ErrorCodeAlreadyRunning       = "already_running"        // 应用已在运行
+	ErrorCodePolicyViolation      = "policy_violation"       // 被命令白名单或签名策略拒绝
+	ErrorCodeUnauthorized         = "unauthorized"           // 缺少或无效的凭证
+	ErrorCodeForbidden            = "forbidden"              // 角色权限不足或调用方不被允许
+	ErrorCodeNotFound             = "not_found"              // 资源不存在
+	ErrorCodeConflict             = "conflict"               // 与资源当前状态冲突
+	ErrorCodeIdempotencyKeyReused = "idempotency_key_reused" // 同一Idempotency-Key对应了不同的请求
+	ErrorCodeUnsupportedMediaType = "unsupported_media_type" // 不支持的请求内容类型
+	ErrorCodeSchemaViolation      = "schema_violation"       // 状态上报不符合manifest中的schema
+	ErrorCodeTooManyRequests      = "too_many_requests"      // 同时处理的请求过多
+	ErrorCodeStartFailed          = "start_failed"           // 应用进程启动失败
+	ErrorCodeInternal             = "internal_error"         // 其他内部错误
+)
+
+// APIError API错误，HTTP接口以 ErrorResponse 返回
+type APIError struct {
+	Code       string                 `json:"code"`
+	Message    string                 `json:"message"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	HTTPStatus int                    `json:"-"`
+}
+
+// ErrorResponse HTTP错误响应
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// NewAPIError 创建API错误
+func NewAPIError(httpStatus int, code, message string) *APIError {
+	return &APIError{Code: code, Message: message, HTTPStatus: httpStatus}
+}
+
+// Error 实现 error
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// Is 错误码相同即视为同一错误，便于 errors.Is(err, models.ErrNotConfigured)
+func (e *APIError) Is(target error) bool {
+	var other *APIError
+	return errors.As(target, &other) && other.Code == e.Code
+}
+
+// WithDetail 返回附带详细信息的副本
+func (e *APIError) WithDetail(key string, value interface{}) *APIError {
+	copied := *e
+	copied.Details = make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		copied.Details[k] = v
+	}
+	copied.Details[key] = value
+	return &copied
+}
+
+// AsAPIError 将任意错误转换为API错误，非 APIError 视为内部错误
+// 内部错误可能包含路径、exec错误等内部信息，只返回通用信息，调用方负责记录原始错误
+func AsAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return ErrInternal
+}
+
+// 固定信息的常用错误
+var (
+	ErrNotConfigured  = NewAPIError(http.StatusConflict, ErrorCodeNotConfigured, "app not configured")
+	ErrAlreadyRunning = NewAPIError(http.StatusConflict, ErrorCodeAlreadyRunning, "app is already running")
+	ErrUnauthorized   = NewAPIError(http.StatusUnauthorized, ErrorCodeUnauthorized, "unauthorized")
+	ErrInternal       = NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, "internal error")
+)
+
+// InvalidRequest 请求体或参数无效
+func InvalidRequest(message string) *APIError {
+	return NewAPIError(http.StatusBadRequest, ErrorCodeInvalidRequest, message)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"time"
 )
 
@@ -25,6 +26,34 @@ type AppInfo struct {
 	AutoRestart         bool              `json:"auto_restart"`
 	MaxRestarts         int               `json:"max_restarts"`
 	HealthCheckInterval int               `json:"health_check_interval"`
+
+	// 环境变量策略
+	CleanEnv     bool              `json:"clean_env"`     // 为true时不继承proxy的环境变量
+	EnvAllowlist []string          `json:"env_allowlist"` // clean_env模式下允许继承的变量名，支持 PREFIX_* 前缀匹配
+	EnvFiles     map[string]string `json:"env_files"`     // 从文件读取值的环境变量（如挂载的secret），值视为敏感信息
+	SecretEnv    []string          `json:"secret_env"`    // 需要在状态和日志中脱敏的环境变量名
+
+	// 进程运行身份
+	WorkingDir          string   `json:"working_dir"`          // 子进程工作目录，默认继承proxy
+	User                string   `json:"user"`                 // 运行用户（用户名或uid）
+	Group               string   `json:"group"`                // 运行组（组名或gid），默认为用户的主组
+	SupplementaryGroups []string `json:"supplementary_groups"` // 附加组（组名或gid）
+	Umask               string   `json:"umask"`                // 八进制umask，如 "0027"
+
+	// Linux沙箱加固（可选）
+	Sandbox *SandboxConfig `json:"sandbox,omitempty"`
+}
+
+// SandboxConfig 应用沙箱配置（仅Linux）
+type SandboxConfig struct {
+	NoNewPrivs          bool     `json:"no_new_privs"`          // 设置 PR_SET_NO_NEW_PRIVS
+	DropCapabilities    []string `json:"drop_capabilities"`     // 需要丢弃的capability，如 CAP_NET_RAW，"ALL" 表示全部
+	NewMountNamespace   bool     `json:"new_mount_namespace"`   // 新建mount命名空间（无权限时降级）
+	NewPIDNamespace     bool     `json:"new_pid_namespace"`     // 新建PID命名空间（无权限时降级）
+	NewNetworkNamespace bool     `json:"new_network_namespace"` // 新建网络命名空间，仅有loopback（无权限时降级）
+	ReadOnlyRoot        bool     `json:"read_only_root"`        // 根文件系统只读，要求mount命名空间
+	WritableDirs        []string `json:"writable_dirs"`         // 只读根下挂载tmpfs的可写目录
+	SeccompProfile      string   `json:"seccomp_profile"`       // seccomp BPF程序文件（seccomp_export_bpf 导出格式）
 }
 
 // AppState 应用运行时状态
@@ -36,18 +65,322 @@ type AppState struct {
 	RestartCount int                    `json:"restart_count"`
 	LastError    *string                `json:"last_error,omitempty"`
 	Config       map[string]interface{} `json:"config,omitempty"`
+	Env          map[string]string      `json:"env,omitempty"` // 注入的环境变量（已脱敏）
+	Sandbox      *SandboxConfig         `json:"sandbox,omitempty"` // 实际生效的沙箱配置
+}
+
+// 生命周期事件类型
+const (
+	EventConfigured  = "configured"
+	EventStarted     = "started"
+	EventStartFailed = "start_failed"
+	EventRunning     = "running"
+	EventStopped     = "stopped"
+	EventRestarted   = "restarted"
+	EventCrashed     = "crashed"
+)
+
+// ActorProxy proxy自身触发的事件（健康检查、自动重启）
+const ActorProxy = "proxy"
+
+// LifecycleEvent 应用生命周期事件
+type LifecycleEvent struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`
+	Actor  string    `json:"actor"`
+	Status AppStatus `json:"status"`
+	Detail string    `json:"detail,omitempty"`
 }
 
-// StatusReport gRPC状态报告
+// StatusReport 应用的一次状态上报：设备信封和内部状态
 type StatusReport struct {
-	Timestamp time.Time              `json:"timestamp"`
-	Status    string                 `json:"status"`
-	Data      map[string]interface{} `json:"data"`
+	DeviceID      string                 `json:"device_id,omitempty"`
+	DeviceType    string                 `json:"device_type,omitempty"`
+	Timestamp     *time.Time             `json:"timestamp,omitempty"` // 设备时间
+	Sequence      uint64                 `json:"sequence,omitempty"`  // 设备侧递增的序号
+	SchemaVersion string                 `json:"schema_version,omitempty"`
+	Status        string                 `json:"status"`
+	ReceivedAt    *time.Time             `json:"received_at,omitempty"` // proxy收到上报的时间
+	Data          map[string]interface{} `json:"data"`
+	Units         map[string]string      `json:"units,omitempty"`      // manifest schema 中声明的字段单位
+	Violations    []SchemaViolation      `json:"violations,omitempty"` // 未通过 schema 校验的字段（flag 模式下仍被接受）
+}
+
+// ReportStatusRequest 应用通过HTTP上报的状态
+type ReportStatusRequest struct {
+	DeviceID      string                 `json:"device_id"`
+	DeviceType    string                 `json:"device_type"`
+	Timestamp     interface{}            `json:"timestamp"` // Unix秒（可带小数）或RFC 3339字符串
+	Sequence      uint64                 `json:"sequence"`
+	SchemaVersion string                 `json:"schema_version"`
+	Status        string                 `json:"status"`
+	Data          map[string]interface{} `json:"data"`
+}
+
+// StatusReport 转换为状态上报，时间戳格式无效时返回 invalid_request
+func (request *ReportStatusRequest) StatusReport() (StatusReport, error) {
+	report := StatusReport{
+		DeviceID:      request.DeviceID,
+		DeviceType:    request.DeviceType,
+		Sequence:      request.Sequence,
+		SchemaVersion: request.SchemaVersion,
+		Status:        request.Status,
+		Data:          request.Data,
+	}
+	switch timestamp := request.Timestamp.(type) {
+	case nil:
+	case float64:
+		sec, frac := math.Modf(timestamp)
+		t := time.Unix(int64(sec), int64(frac*1e9))
+		report.Timestamp = &t
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return report, InvalidRequest("timestamp must be Unix seconds or an RFC 3339 string")
+		}
+		report.Timestamp = &t
+	default:
+		return report, InvalidRequest("timestamp must be Unix seconds or an RFC 3339 string")
+	}
+	return report, nil
+}
+
+// ReportStatusResponse 状态上报的响应，revision 为更新后内部状态的版本号
+type ReportStatusResponse struct {
+	Status     string            `json:"status"`
+	Revision   uint64            `json:"revision"`
+	Violations []SchemaViolation `json:"violations,omitempty"` // flag 模式下被接受但未通过 schema 校验的字段
+}
+
+// DataHistory 内部状态中一个字段的历史
+type DataHistory struct {
+	Field  string      `json:"field"`
+	From   time.Time   `json:"from"`
+	To     time.Time   `json:"to"`
+	Step   string      `json:"step,omitempty"` // 降采样的桶宽度，为空时为原始样本
+	Agg    string      `json:"agg,omitempty"`  // 桶内的聚合方式
+	Points []DataPoint `json:"points"`
+}
+
+// DataPoint 历史中的一个点，降采样时 time 为桶的起始时间
+type DataPoint struct {
+	Time  time.Time   `json:"time"`
+	Value interface{} `json:"value"` // 数值或字符串
+}
+
+// DataStats 内部状态数值字段的滚动统计
+type DataStats struct {
+	Windows []string              `json:"windows"` // 配置的统计窗口
+	Fields  map[string]FieldStats `json:"fields"`
+}
+
+// FieldStats 一个数值字段的统计，windows 中只包含有样本的窗口
+type FieldStats struct {
+	Last       float64                `json:"last"`
+	LastChange time.Time              `json:"last_change"` // 值最近一次变化（或首次出现）的时间
+	Windows    map[string]WindowStats `json:"windows"`
+}
+
+// WindowStats 一个窗口内的统计
+type WindowStats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Avg    float64 `json:"avg"`
+	StdDev float64 `json:"stddev"` // 总体标准差
+}
+
+// JSONPatchOperation RFC 6902 JSON Patch 操作
+type JSONPatchOperation struct {
+	Op    string      `json:"op" binding:"required"` // add、remove、replace、move、copy、test
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MetricsConfig manifest中内部状态字段到Prometheus指标的映射
+type MetricsConfig struct {
+	Fields []MetricField `json:"fields"` // 为空时导出所有数值字段
+}
+
+// MetricField 导出为指标的内部状态字段，非数值的值会被跳过
+type MetricField struct {
+	Field string `json:"field"` // 内部状态中的字段名，嵌套字段用 "." 分隔
+	Name  string `json:"name"`  // 指标名，为空时导出为 brick_app_data{field="<field>"}
+	Help  string `json:"help"`
+}
+
+// schema 校验失败时的处理方式
+const (
+	SchemaValidationReject = "reject" // 拒绝上报，返回 schema_violation
+	SchemaValidationFlag   = "flag"   // 接受上报，在 violations 中标记
+)
+
+// DeviceSchema manifest中一种 device_type 的内部状态结构
+type DeviceSchema struct {
+	Validation       string                 `json:"validation"`        // reject（默认）或 flag
+	AdditionalFields bool                   `json:"additional_fields"` // 是否允许 fields 中未声明的字段
+	Fields           map[string]FieldSchema `json:"fields"`
+}
+
+// FieldSchema 内部状态中一个顶层字段的约束
+type FieldSchema struct {
+	Type     string        `json:"type"` // number、integer、string、boolean、object、array
+	Unit     string        `json:"unit,omitempty"`
+	Min      *float64      `json:"min,omitempty"`
+	Max      *float64      `json:"max,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+	Required bool          `json:"required,omitempty"`
+}
+
+// SchemaViolation 一个字段未通过 schema 校验的原因
+type SchemaViolation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// 告警状态
+const (
+	AlertStatePending  = "pending"  // 条件已满足，尚未达到规则的持续次数或时长
+	AlertStateFiring   = "firing"   // 告警中
+	AlertStateResolved = "resolved" // 条件不再满足，告警已解除
+)
+
+// Alert 告警规则产生的告警，同一规则同时只有一个未解除的告警
+type Alert struct {
+	ID         string                 `json:"id"`
+	Rule       string                 `json:"rule"`
+	Expr       string                 `json:"expr"`
+	Severity   string                 `json:"severity,omitempty"`
+	Summary    string                 `json:"summary,omitempty"`
+	State      string                 `json:"state"`
+	ActiveAt   time.Time              `json:"active_at"` // 条件首次满足的时间
+	FiredAt    *time.Time             `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time             `json:"resolved_at,omitempty"`
+	Reports    int                    `json:"reports"`          // 连续满足条件的上报次数
+	Values     map[string]interface{} `json:"values,omitempty"` // 最近一次满足条件时表达式引用的字段值
+}
+
+// AlertRulesReloaded 重新加载告警规则的结果
+type AlertRulesReloaded struct {
+	Rules int `json:"rules"` // 加载的规则数量
+}
+
+// 异步生命周期操作类型
+const (
+	OperationStart   = "start"
+	OperationStop    = "stop"
+	OperationRestart = "restart"
+)
+
+// OperationStatus 异步操作状态
+type OperationStatus string
+
+const (
+	OperationStatusRunning   OperationStatus = "running"
+	OperationStatusSucceeded OperationStatus = "succeeded"
+	OperationStatusFailed    OperationStatus = "failed"
+)
+
+// Operation 后台执行的生命周期操作
+type Operation struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Status      OperationStatus `json:"status"`
+	Actor       string          `json:"actor"`
+	CreatedAt   time.Time       `json:"created_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Result      interface{}     `json:"result,omitempty"` // 成功时为对应同步接口的响应
+	Error       *APIError       `json:"error,omitempty"`
+}
+
+// 事件流中的事件类型
+const (
+	StreamEventLifecycle = "lifecycle" // 生命周期事件，data 为 LifecycleEvent
+	StreamEventStatus    = "status"    // 应用状态上报，data 为上报的内部状态
+	StreamEventLog       = "log"       // 应用输出，data 为 LogLine
+	StreamEventAlert     = "alert"     // 告警状态变化，data 为 Alert
+)
+
+// LogLine 应用标准输出或标准错误的一行
+type LogLine struct {
+	Stream string `json:"stream"` // stdout 或 stderr
+	Line   string `json:"line"`
+}
+
+// StreamEvent 事件流中的事件，ID在proxy进程内单调递增
+type StreamEvent struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// CommandStatus 下发给应用的命令状态
+type CommandStatus string
+
+const (
+	CommandStatusPending   CommandStatus = "pending"   // 等待应用接收
+	CommandStatusDelivered CommandStatus = "delivered" // 已下发，等待应用回执
+	CommandStatusSucceeded CommandStatus = "succeeded"
+	CommandStatusFailed    CommandStatus = "failed"
+	CommandStatusExpired   CommandStatus = "expired" // 截止时间前未完成
+)
+
+// Command 下发给应用的命令
+type Command struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+	Deadline    time.Time              `json:"deadline"`
+	Status      CommandStatus          `json:"status"`
+	Actor       string                 `json:"actor"`
+	CreatedAt   time.Time              `json:"created_at"`
+	DeliveredAt *time.Time             `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+	Result      map[string]interface{} `json:"result,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// Done 命令是否已结束
+func (command *Command) Done() bool {
+	switch command.Status {
+	case CommandStatusSucceeded, CommandStatusFailed, CommandStatusExpired:
+		return true
+	}
+	return false
+}
+
+// Envelope /v1 接口的成功响应，错误仍以 ErrorResponse 返回
+type Envelope struct {
+	Data interface{}   `json:"data"`
+	Meta *EnvelopeMeta `json:"meta"`
+}
+
+// EnvelopeMeta 响应的公共信息
+type EnvelopeMeta struct {
+	ProcessID string `json:"process_id"`
+}
+
+// ProcessView /v1/app/process 的响应数据
+type ProcessView struct {
+	Status *AppStatusResponse     `json:"status"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+// DeprecatedRoute 已弃用的旧路径及其使用情况
+type DeprecatedRoute struct {
+	Method    string     `json:"method"`
+	Route     string     `json:"route"`
+	Successor string     `json:"successor"`
+	Count     uint64     `json:"count"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
 }
 
 // HTTP请求/响应结构
 type ConfigureAppRequest struct {
-	AppInfo AppInfo `json:"app_info"`
+	AppInfo   AppInfo `json:"app_info"`
+	Signature string  `json:"signature,omitempty"` // 对 app_info 原始JSON字节的ed25519签名（base64）
 }
 
 type ConfigureAppResponse struct {
@@ -92,6 +425,21 @@ type AppStatusResponse struct {
 	RestartCount int                   `json:"restart_count"`
 	LastError   *string                `json:"last_error,omitempty"`
 	Config      map[string]interface{} `json:"config,omitempty"`
+	Env         map[string]string      `json:"env,omitempty"`
+	Sandbox     *SandboxConfig         `json:"sandbox,omitempty"`
+}
+
+type CommandRequest struct {
+	Name     string                 `json:"name" binding:"required"`
+	Params   map[string]interface{} `json:"params"`
+	Deadline *time.Time             `json:"deadline"` // 为空时使用默认超时
+}
+
+// CommandResultRequest 应用对命令的回执
+type CommandResultRequest struct {
+	Success bool                   `json:"success"`
+	Result  map[string]interface{} `json:"result"`
+	Error   string                 `json:"error"`
 }
 
 type HealthCheckResponse struct {
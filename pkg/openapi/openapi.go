@@ -0,0 +1,206 @@
+// Package openapi 根据Go类型生成OpenAPI 3文档
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Version 生成的文档使用的OpenAPI版本
+const Version = "3.0.3"
+
+// Document OpenAPI文档
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info 文档信息
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Components 可复用的schema和安全方案
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 认证方案
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// PathItem 一个路径下各方法的操作，键为小写的HTTP方法
+type PathItem map[string]*Operation
+
+// Operation 接口操作
+type Operation struct {
+	Summary      string                `json:"summary"`
+	Tags         []string              `json:"tags,omitempty"`
+	Parameters   []Parameter           `json:"parameters,omitempty"`
+	RequestBody  *RequestBody          `json:"requestBody,omitempty"`
+	Responses    map[string]*Response  `json:"responses"`
+	Security     []map[string][]string `json:"security,omitempty"`
+	Deprecated   bool                  `json:"deprecated,omitempty"`
+	RequiredRole string                `json:"x-required-role,omitempty"` // 启用认证时要求的角色
+}
+
+// Parameter 路径、查询或请求头参数
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response 响应
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType 某种内容类型的schema
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema JSON Schema（OpenAPI子集）
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// Generator 把Go类型转换为schema，具名结构体登记到 components.schemas
+type Generator struct {
+	schemas map[string]*Schema
+}
+
+// NewGenerator 创建schema生成器
+func NewGenerator() *Generator {
+	return &Generator{schemas: make(map[string]*Schema)}
+}
+
+// Schemas 已登记的具名schema
+func (g *Generator) Schemas() map[string]*Schema {
+	return g.schemas
+}
+
+// Schema 返回值v的类型对应的schema，v为nil时返回nil
+func (g *Generator) Schema(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return g.schemaOf(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			// 先占位，支持递归引用
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	// interface{} 等任意值
+	return &Schema{}
+}
+
+// structSchema 按json标签生成结构体的schema，binding:"required" 的字段为必填
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := g.structSchema(indirect(field.Type))
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = g.schemaOf(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// schemaName 具名类型的schema名，非 models 包的类型带上包名
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if pkg == "" || strings.HasSuffix(pkg, "/models") {
+		return t.Name()
+	}
+	return pkg[strings.LastIndex(pkg, "/")+1:] + "." + t.Name()
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,381 @@
+package policy
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/spf13/viper"
+)
+
+// ErrForbidden 配置被策略拒绝
+var ErrForbidden = errors.New("forbidden by policy")
+
+// loaderEnv 可以改变程序加载和解释方式的环境变量，启用命令白名单时默认禁止注入
+// 例如 LD_PRELOAD 可以让白名单中的程序加载任意代码
+var loaderEnv = []string{
+	"LD_*",
+	"DYLD_*",
+	"GCONV_PATH",
+	"GODEBUG",
+	"BASH_ENV",
+	"ENV",
+	"PYTHONPATH",
+	"PYTHONHOME",
+	"PYTHONSTARTUP",
+	"PERL5LIB",
+	"PERL5OPT",
+	"RUBYOPT",
+	"NODE_OPTIONS",
+	"JAVA_TOOL_OPTIONS",
+}
+
+// CommandRule 允许执行的命令规则
+type CommandRule struct {
+	Path   string   `mapstructure:"path"`   // 可执行文件绝对路径，支持 filepath.Match 通配符
+	SHA256 string   `mapstructure:"sha256"` // 可选，可执行文件内容的sha256
+	Args   []string `mapstructure:"args"`   // 可选，逐个匹配启动参数（模板原文）的正则表达式，配置后参数个数必须一致
+}
+
+// Config 配置文件中的 policy 段
+type Config struct {
+	Commands         []CommandRule `mapstructure:"commands"`
+	Hashes           []string      `mapstructure:"hashes"`        // 不限路径、按内容放行的sha256
+	AllowedEnv       []string      `mapstructure:"allowed_env"`   // 允许注入的加载器相关环境变量，支持 PREFIX_*
+	EnvFileDirs      []string      `mapstructure:"env_file_dirs"` // env_files 允许读取的目录
+	RequireSignature bool          `mapstructure:"require_signature"`
+	PublicKeys       []string      `mapstructure:"public_keys"`      // base64编码的ed25519公钥
+	PublicKeyFiles   []string      `mapstructure:"public_key_files"` // PEM(PKIX)格式的ed25519公钥文件
+}
+
+// commandRule 编译后的命令规则
+type commandRule struct {
+	CommandRule
+	args []*regexp.Regexp // 为nil时不限制参数
+}
+
+// Policy 应用配置的安全策略
+type Policy struct {
+	commands         []commandRule
+	hashes           map[string]bool
+	allowedEnv       []string
+	envFileDirs      []string
+	requireSignature bool
+	publicKeys       []ed25519.PublicKey
+}
+
+// Load 从viper配置加载策略，未配置时不做限制
+func Load() (*Policy, error) {
+	var config Config
+	if err := viper.UnmarshalKey("policy", &config); err != nil {
+		return nil, fmt.Errorf("invalid policy config: %v", err)
+	}
+	return New(config)
+}
+
+// New 根据配置创建策略
+func New(config Config) (*Policy, error) {
+	p := &Policy{
+		hashes:           make(map[string]bool),
+		allowedEnv:       config.AllowedEnv,
+		requireSignature: config.RequireSignature,
+	}
+	for _, rule := range config.Commands {
+		if !filepath.IsAbs(rule.Path) {
+			return nil, fmt.Errorf("policy command path %q must be absolute", rule.Path)
+		}
+		if _, err := filepath.Match(rule.Path, ""); err != nil {
+			return nil, fmt.Errorf("invalid policy command path %q: %v", rule.Path, err)
+		}
+		// 目录部分跟随符号链接（如 /bin -> /usr/bin），与命令的解析方式保持一致
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(rule.Path)); err == nil {
+			rule.Path = filepath.Join(dir, filepath.Base(rule.Path))
+		}
+		rule.SHA256 = strings.ToLower(rule.SHA256)
+		compiled := commandRule{CommandRule: rule}
+		if rule.Args != nil {
+			compiled.args = make([]*regexp.Regexp, 0, len(rule.Args))
+		}
+		for _, pattern := range rule.Args {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid policy args pattern %q for %s: %v", pattern, rule.Path, err)
+			}
+			compiled.args = append(compiled.args, re)
+		}
+		p.commands = append(p.commands, compiled)
+	}
+	for _, hash := range config.Hashes {
+		p.hashes[strings.ToLower(hash)] = true
+	}
+	for _, dir := range config.EnvFileDirs {
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("policy env file dir %q must be absolute", dir)
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		p.envFileDirs = append(p.envFileDirs, filepath.Clean(dir))
+	}
+	for _, encoded := range config.PublicKeys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key %q", encoded)
+		}
+		p.publicKeys = append(p.publicKeys, ed25519.PublicKey(key))
+	}
+	for _, path := range config.PublicKeyFiles {
+		key, err := readPublicKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		p.publicKeys = append(p.publicKeys, key)
+	}
+	if p.requireSignature && len(p.publicKeys) == 0 {
+		return nil, fmt.Errorf("policy require_signature needs at least one public key")
+	}
+	return p, nil
+}
+
+// RequireSignature 是否要求配置请求携带签名
+func (p *Policy) RequireSignature() bool {
+	return p != nil && p.requireSignature
+}
+
+// VerifySignature 校验 app_info 原始JSON的ed25519签名（base64编码）
+// 未要求签名且未携带签名时直接通过
+func (p *Policy) VerifySignature(payload []byte, signature string) error {
+	if signature == "" {
+		if p.RequireSignature() {
+			return fmt.Errorf("%w: signature required", ErrForbidden)
+		}
+		return nil
+	}
+	if p == nil || len(p.publicKeys) == 0 {
+		return fmt.Errorf("%w: no public key configured to verify signature", ErrForbidden)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: invalid signature encoding", ErrForbidden)
+	}
+	for _, key := range p.publicKeys {
+		if ed25519.Verify(key, payload, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature verification failed", ErrForbidden)
+}
+
+// restrictsCommands 是否配置了命令白名单
+func (p *Policy) restrictsCommands() bool {
+	return p != nil && (len(p.commands) > 0 || len(p.hashes) > 0)
+}
+
+// CheckCommand 校验应用命令和参数是否在白名单中，未配置白名单时不做限制
+func (p *Policy) CheckCommand(appInfo *models.AppInfo) error {
+	if !p.restrictsCommands() {
+		return nil
+	}
+
+	path, err := resolveCommand(appInfo.Command, appInfo.WorkingDir)
+	if err != nil {
+		return fmt.Errorf("%w: command %q: %v", ErrForbidden, appInfo.Command, err)
+	}
+
+	var hash string
+	fileHash := func() (string, error) {
+		if hash == "" {
+			h, err := hashFile(path)
+			if err != nil {
+				return "", err
+			}
+			hash = h
+		}
+		return hash, nil
+	}
+
+	for _, rule := range p.commands {
+		if matched, _ := filepath.Match(rule.Path, path); !matched {
+			continue
+		}
+		if !rule.matchArgs(appInfo.Args) {
+			continue
+		}
+		if rule.SHA256 == "" {
+			return nil
+		}
+		h, err := fileHash()
+		if err != nil {
+			return fmt.Errorf("%w: command %q: %v", ErrForbidden, path, err)
+		}
+		if h == rule.SHA256 {
+			return nil
+		}
+	}
+	if len(p.hashes) > 0 {
+		h, err := fileHash()
+		if err != nil {
+			return fmt.Errorf("%w: command %q: %v", ErrForbidden, path, err)
+		}
+		if p.hashes[h] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: command %q with args %q is not allowed", ErrForbidden, path, appInfo.Args)
+}
+
+// matchArgs 判断启动参数是否符合规则，规则未限制参数时总是符合
+func (rule commandRule) matchArgs(args []string) bool {
+	if rule.args == nil {
+		return true
+	}
+	if len(args) != len(rule.args) {
+		return false
+	}
+	for i, re := range rule.args {
+		if !re.MatchString(args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckEnv 校验注入子进程的环境变量
+// 启用命令白名单时禁止注入加载器相关的变量（allowed_env 中的除外），配置 env_file_dirs 时 env_files 只能位于这些目录中
+func (p *Policy) CheckEnv(appInfo *models.AppInfo) error {
+	if p == nil {
+		return nil
+	}
+	if p.restrictsCommands() {
+		for name := range appInfo.Env {
+			if err := p.checkEnvName(name); err != nil {
+				return err
+			}
+		}
+		for name := range appInfo.EnvFiles {
+			if err := p.checkEnvName(name); err != nil {
+				return err
+			}
+		}
+	}
+	if len(p.envFileDirs) > 0 {
+		for name, path := range appInfo.EnvFiles {
+			if !p.envFileAllowed(path) {
+				return fmt.Errorf("%w: env file %q for %s is outside env_file_dirs", ErrForbidden, path, name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkEnvName 加载器相关的变量需要策略显式允许
+func (p *Policy) checkEnvName(name string) error {
+	if matchEnvName(name, loaderEnv) && !matchEnvName(name, p.allowedEnv) {
+		return fmt.Errorf("%w: env %s is not allowed", ErrForbidden, name)
+	}
+	return nil
+}
+
+// envFileAllowed 判断文件的真实路径（跟随符号链接）是否位于允许的目录中
+func (p *Policy) envFileAllowed(path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range p.envFileDirs {
+		if rel, err := filepath.Rel(dir, resolved); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEnvName 判断变量名是否匹配列表，支持 PREFIX_* 前缀匹配
+func matchEnvName(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCommand 将命令解析为真实的绝对路径（跟随符号链接）
+func resolveCommand(command, workingDir string) (string, error) {
+	path := command
+	if !strings.Contains(command, "/") {
+		lookedUp, err := exec.LookPath(command)
+		if err != nil {
+			return "", err
+		}
+		path = lookedUp
+	}
+	if !filepath.IsAbs(path) {
+		base := workingDir
+		if base == "" {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return "", err
+			}
+			base = cwd
+		}
+		path = filepath.Join(base, path)
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// hashFile 计算文件内容的sha256
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readPublicKeyFile 读取PEM格式的ed25519公钥
+func readPublicKeyFile(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key file: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key file %s: no PEM block", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key file %s: %v", path, err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key file %s: not an ed25519 key", path)
+	}
+	return edKey, nil
+}
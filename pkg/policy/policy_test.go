@@ -0,0 +1,197 @@
+package policy
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"brick-smart-template/pkg/models"
+)
+
+// writeExecutable 在临时目录中创建可执行文件，返回真实路径和内容的sha256
+func writeExecutable(t *testing.T, dir, name, content string) (string, string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return resolved, hex.EncodeToString(sum[:])
+}
+
+// newTestPolicy 根据配置创建策略
+func newTestPolicy(t *testing.T, config Config) *Policy {
+	t.Helper()
+	p, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCheckCommand(t *testing.T) {
+	dir := t.TempDir()
+	cleaner, cleanerHash := writeExecutable(t, dir, "cleaner", "#!/bin/sh\n")
+	other, otherHash := writeExecutable(t, dir, "other", "#!/bin/sh\nexit 1\n")
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(other, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  Config
+		appInfo models.AppInfo
+		allowed bool
+	}{
+		{"no allowlist", Config{}, models.AppInfo{Command: other}, true},
+		{"exact path", Config{Commands: []CommandRule{{Path: cleaner}}}, models.AppInfo{Command: cleaner}, true},
+		{"other path", Config{Commands: []CommandRule{{Path: cleaner}}}, models.AppInfo{Command: other}, false},
+		{"glob path", Config{Commands: []CommandRule{{Path: filepath.Join(dir, "*")}}}, models.AppInfo{Command: other}, true},
+		{"symlink resolved", Config{Commands: []CommandRule{{Path: filepath.Join(dir, "link")}}}, models.AppInfo{Command: link}, false},
+		{"relative to working dir", Config{Commands: []CommandRule{{Path: cleaner}}}, models.AppInfo{Command: "./cleaner", WorkingDir: dir}, true},
+		{"path and hash", Config{Commands: []CommandRule{{Path: cleaner, SHA256: cleanerHash}}}, models.AppInfo{Command: cleaner}, true},
+		{"path with wrong hash", Config{Commands: []CommandRule{{Path: cleaner, SHA256: otherHash}}}, models.AppInfo{Command: cleaner}, false},
+		{"hash anywhere", Config{Hashes: []string{otherHash}}, models.AppInfo{Command: link}, true},
+		{"unknown hash", Config{Hashes: []string{cleanerHash}}, models.AppInfo{Command: other}, false},
+		{"missing command", Config{Commands: []CommandRule{{Path: filepath.Join(dir, "*")}}}, models.AppInfo{Command: filepath.Join(dir, "missing")}, false},
+		{"pinned args", Config{Commands: []CommandRule{{Path: cleaner, Args: []string{"-room", `\w+`}}}}, models.AppInfo{Command: cleaner, Args: []string{"-room", "kitchen"}}, true},
+		{"pinned args mismatch", Config{Commands: []CommandRule{{Path: cleaner, Args: []string{"-room", `\w+`}}}}, models.AppInfo{Command: cleaner, Args: []string{"-room", "kitchen; rm"}}, false},
+		{"extra args", Config{Commands: []CommandRule{{Path: cleaner, Args: []string{"-room", `\w+`}}}}, models.AppInfo{Command: cleaner, Args: []string{"-room", "kitchen", "-c"}}, false},
+		{"no args allowed", Config{Commands: []CommandRule{{Path: cleaner, Args: []string{}}}}, models.AppInfo{Command: cleaner, Args: []string{"-c", "import os"}}, false},
+		{"args pattern is anchored", Config{Commands: []CommandRule{{Path: cleaner, Args: []string{"script.py"}}}}, models.AppInfo{Command: cleaner, Args: []string{"-cscript.py"}}, false},
+		{"second rule matches args", Config{Commands: []CommandRule{{Path: cleaner, Args: []string{}}, {Path: cleaner, Args: []string{"app.py"}}}}, models.AppInfo{Command: cleaner, Args: []string{"app.py"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newTestPolicy(t, tt.config).CheckCommand(&tt.appInfo)
+			if tt.allowed && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrForbidden) {
+				t.Fatalf("got %v, want ErrForbidden", err)
+			}
+		})
+	}
+}
+
+func TestCheckEnv(t *testing.T) {
+	secrets := t.TempDir()
+	tokenFile := filepath.Join(secrets, "token")
+	outside := filepath.Join(t.TempDir(), "shadow")
+	for _, path := range []string{tokenFile, outside} {
+		if err := os.WriteFile(path, []byte("secret"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	escape := filepath.Join(secrets, "escape")
+	if err := os.Symlink(outside, escape); err != nil {
+		t.Fatal(err)
+	}
+	commands := []CommandRule{{Path: "/app/cleaner"}}
+
+	tests := []struct {
+		name    string
+		config  Config
+		appInfo models.AppInfo
+		allowed bool
+	}{
+		{"plain env", Config{Commands: commands}, models.AppInfo{Env: map[string]string{"ROOM": "kitchen"}}, true},
+		{"LD_PRELOAD", Config{Commands: commands}, models.AppInfo{Env: map[string]string{"LD_PRELOAD": "/tmp/evil.so"}}, false},
+		{"LD_LIBRARY_PATH", Config{Hashes: []string{"00"}}, models.AppInfo{Env: map[string]string{"LD_LIBRARY_PATH": "/tmp"}}, false},
+		{"GODEBUG", Config{Commands: commands}, models.AppInfo{Env: map[string]string{"GODEBUG": "x=1"}}, false},
+		{"loader env from file", Config{Commands: commands}, models.AppInfo{EnvFiles: map[string]string{"PYTHONPATH": tokenFile}}, false},
+		{"allowed loader env", Config{Commands: commands, AllowedEnv: []string{"LD_*"}}, models.AppInfo{Env: map[string]string{"LD_LIBRARY_PATH": "/opt/lib"}}, true},
+		{"loader env without allowlist", Config{}, models.AppInfo{Env: map[string]string{"LD_PRELOAD": "/tmp/evil.so"}}, true},
+		{"env file in dir", Config{EnvFileDirs: []string{secrets}}, models.AppInfo{EnvFiles: map[string]string{"TOKEN": tokenFile}}, true},
+		{"env file outside dir", Config{EnvFileDirs: []string{secrets}}, models.AppInfo{EnvFiles: map[string]string{"TOKEN": outside}}, false},
+		{"env file traversal", Config{EnvFileDirs: []string{secrets}}, models.AppInfo{EnvFiles: map[string]string{"TOKEN": secrets + "/../" + filepath.Base(filepath.Dir(outside)) + "/shadow"}}, false},
+		{"env file symlink escape", Config{EnvFileDirs: []string{secrets}}, models.AppInfo{EnvFiles: map[string]string{"TOKEN": escape}}, false},
+		{"relative env file", Config{EnvFileDirs: []string{secrets}}, models.AppInfo{EnvFiles: map[string]string{"TOKEN": "token"}}, false},
+		{"env files without dirs", Config{}, models.AppInfo{EnvFiles: map[string]string{"TOKEN": outside}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newTestPolicy(t, tt.config).CheckEnv(&tt.appInfo)
+			if tt.allowed && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrForbidden) {
+				t.Fatalf("got %v, want ErrForbidden", err)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"name":"cleaner","command":"/app/cleaner"}`)
+	sign := func(key ed25519.PrivateKey, data []byte) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	}
+	required := newTestPolicy(t, Config{RequireSignature: true, PublicKeys: []string{base64.StdEncoding.EncodeToString(public)}})
+
+	tests := []struct {
+		name      string
+		policy    *Policy
+		payload   []byte
+		signature string
+		allowed   bool
+	}{
+		{"valid signature", required, payload, sign(private, payload), true},
+		{"missing signature", required, payload, "", false},
+		{"tampered payload", required, []byte(`{"name":"cleaner","command":"/bin/sh"}`), sign(private, payload), false},
+		{"wrong key", required, payload, sign(otherPrivate, payload), false},
+		{"invalid encoding", required, payload, "not base64!", false},
+		{"not required", newTestPolicy(t, Config{}), payload, "", true},
+		{"signature without key", newTestPolicy(t, Config{}), payload, sign(private, payload), false},
+		{"nil policy", nil, payload, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.VerifySignature(tt.payload, tt.signature)
+			if tt.allowed && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrForbidden) {
+				t.Fatalf("got %v, want ErrForbidden", err)
+			}
+		})
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"relative command path", Config{Commands: []CommandRule{{Path: "cleaner"}}}},
+		{"invalid glob", Config{Commands: []CommandRule{{Path: "/app/["}}}},
+		{"invalid args pattern", Config{Commands: []CommandRule{{Path: "/app/cleaner", Args: []string{"("}}}}},
+		{"relative env file dir", Config{EnvFileDirs: []string{"secrets"}}},
+		{"invalid public key", Config{PublicKeys: []string{"abc"}}},
+		{"signature without key", Config{RequireSignature: true}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.config); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"brick-smart-template/pkg/models"
+)
+
+// 沙箱初始化进程的约定：proxy以 initArg 作为argv[0]重新执行自身，
+// 在子进程中应用隔离设置后再exec真正的应用（Go无法在fork与exec之间执行代码）
+const (
+	initArg = "brick-sandbox-init"
+	specEnv = "BRICK_SANDBOX_SPEC"
+
+	// maxSeccompInstructions 内核允许的BPF指令上限
+	maxSeccompInstructions = 4096
+)
+
+// capabilities Linux capability名称到编号的映射
+var capabilities = map[string]int{
+	"CAP_CHOWN":              0,
+	"CAP_DAC_OVERRIDE":       1,
+	"CAP_DAC_READ_SEARCH":    2,
+	"CAP_FOWNER":             3,
+	"CAP_FSETID":             4,
+	"CAP_KILL":               5,
+	"CAP_SETGID":             6,
+	"CAP_SETUID":             7,
+	"CAP_SETPCAP":            8,
+	"CAP_LINUX_IMMUTABLE":    9,
+	"CAP_NET_BIND_SERVICE":   10,
+	"CAP_NET_BROADCAST":      11,
+	"CAP_NET_ADMIN":          12,
+	"CAP_NET_RAW":            13,
+	"CAP_IPC_LOCK":           14,
+	"CAP_IPC_OWNER":          15,
+	"CAP_SYS_MODULE":         16,
+	"CAP_SYS_RAWIO":          17,
+	"CAP_SYS_CHROOT":         18,
+	"CAP_SYS_PTRACE":         19,
+	"CAP_SYS_PACCT":          20,
+	"CAP_SYS_ADMIN":          21,
+	"CAP_SYS_BOOT":           22,
+	"CAP_SYS_NICE":           23,
+	"CAP_SYS_RESOURCE":       24,
+	"CAP_SYS_TIME":           25,
+	"CAP_SYS_TTY_CONFIG":     26,
+	"CAP_MKNOD":              27,
+	"CAP_LEASE":              28,
+	"CAP_AUDIT_WRITE":        29,
+	"CAP_AUDIT_CONTROL":      30,
+	"CAP_SETFCAP":            31,
+	"CAP_MAC_OVERRIDE":       32,
+	"CAP_MAC_ADMIN":          33,
+	"CAP_SYSLOG":             34,
+	"CAP_WAKE_ALARM":         35,
+	"CAP_BLOCK_SUSPEND":      36,
+	"CAP_AUDIT_READ":         37,
+	"CAP_PERFMON":            38,
+	"CAP_BPF":                39,
+	"CAP_CHECKPOINT_RESTORE": 40,
+}
+
+// Credential 沙箱内切换的运行身份
+type Credential struct {
+	UID    uint32   `json:"uid"`
+	GID    uint32   `json:"gid"`
+	Groups []uint32 `json:"groups"`
+}
+
+// spec 传递给沙箱初始化进程的描述，Config 为nil时只设置umask后exec应用
+type spec struct {
+	Path       string                `json:"path"`
+	Args       []string              `json:"args"`
+	Config     *models.SandboxConfig `json:"config,omitempty"`
+	Credential *Credential           `json:"credential,omitempty"`
+	Umask      *int                  `json:"umask,omitempty"`
+}
+
+// Command 沙箱化的子进程命令
+type Command struct {
+	Cmd    *exec.Cmd             // 实际启动的命令（沙箱初始化进程）
+	Config *models.SandboxConfig // 实际生效的沙箱配置
+
+	app        *exec.Cmd
+	credential *Credential
+	umask      *int
+}
+
+// IsInit 判断当前进程是否为沙箱初始化进程
+func IsInit() bool {
+	return filepath.Base(os.Args[0]) == initArg
+}
+
+// Validate 配置时校验沙箱设置
+func Validate(config *models.SandboxConfig, switchUser bool) error {
+	if config == nil {
+		return nil
+	}
+	if _, err := capabilityList(config.DropCapabilities); err != nil {
+		return err
+	}
+	for _, dir := range config.WritableDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("sandbox writable dir %q must be absolute", dir)
+		}
+	}
+	if config.SeccompProfile != "" {
+		if _, err := readSeccompProfile(config.SeccompProfile); err != nil {
+			return err
+		}
+		// 非特权进程加载seccomp过滤器必须先设置 no_new_privs
+		if switchUser && !config.NoNewPrivs {
+			return fmt.Errorf("sandbox seccomp_profile with user requires no_new_privs")
+		}
+	}
+	return nil
+}
+
+// New 将应用命令包装为沙箱初始化进程，umask 不为nil时在沙箱内设置
+func New(app *exec.Cmd, config *models.SandboxConfig, credential *Credential, umask *int) (*Command, error) {
+	if app.Err != nil {
+		return nil, app.Err
+	}
+	effective := *config
+	// 只读根和可写目录依赖独立的mount命名空间
+	if effective.ReadOnlyRoot || len(effective.WritableDirs) > 0 {
+		effective.NewMountNamespace = true
+	}
+	command := &Command{app: app, credential: credential, umask: umask}
+	if err := command.build(&effective); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// WithUmask 将应用命令包装为只设置umask的初始化进程
+// umask是进程级属性，在proxy中临时切换会影响其他goroutine创建的文件，因此在子进程中设置
+func WithUmask(app *exec.Cmd, umask int) (*exec.Cmd, error) {
+	if app.Err != nil {
+		return nil, app.Err
+	}
+	cmd, err := initCommand(app, spec{Path: app.Path, Args: app.Args, Umask: &umask})
+	if err != nil {
+		return nil, err
+	}
+	// 运行身份由初始化进程继承
+	cmd.SysProcAttr = app.SysProcAttr
+	return cmd, nil
+}
+
+// Degrade 去掉命名空间后重建命令，用于无权限创建命名空间的环境
+func (c *Command) Degrade() error {
+	if c.Config.ReadOnlyRoot || len(c.Config.WritableDirs) > 0 {
+		return fmt.Errorf("read_only_root and writable_dirs require a mount namespace")
+	}
+	effective := *c.Config
+	effective.NewMountNamespace = false
+	effective.NewPIDNamespace = false
+	effective.NewNetworkNamespace = false
+	return c.build(&effective)
+}
+
+// HasNamespaces 是否请求了新的命名空间
+func (c *Command) HasNamespaces() bool {
+	return c.Config.NewMountNamespace || c.Config.NewPIDNamespace || c.Config.NewNetworkNamespace
+}
+
+// build 根据生效配置构建沙箱初始化进程命令
+func (c *Command) build(effective *models.SandboxConfig) error {
+	cmd, err := initCommand(c.app, spec{
+		Path:       c.app.Path,
+		Args:       c.app.Args,
+		Config:     effective,
+		Credential: c.credential,
+		Umask:      c.umask,
+	})
+	if err != nil {
+		return err
+	}
+	if err := setNamespaces(cmd, effective); err != nil {
+		return err
+	}
+	c.Cmd = cmd
+	c.Config = effective
+	return nil
+}
+
+// initCommand 构建以 initArg 重新执行proxy自身的初始化进程命令
+func initCommand(app *exec.Cmd, s spec) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate proxy executable: %v", err)
+	}
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return &exec.Cmd{
+		Path: self,
+		Args: []string{initArg},
+		Env:  append(append([]string{}, app.Env...), specEnv+"="+string(payload)),
+		Dir:  app.Dir,
+		// 初始化进程exec应用后，应用继承这些输出
+		Stdout:    app.Stdout,
+		Stderr:    app.Stderr,
+		WaitDelay: app.WaitDelay,
+	}, nil
+}
+
+// IsPermissionError 判断启动失败是否由权限不足引起
+func IsPermissionError(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES)
+}
+
+// capabilityList 将capability名称解析为编号，"ALL" 表示全部
+func capabilityList(names []string) ([]int, error) {
+	var result []int
+	for _, name := range names {
+		upper := strings.ToUpper(name)
+		if upper == "ALL" {
+			result = result[:0]
+			for _, value := range capabilities {
+				result = append(result, value)
+			}
+			return result, nil
+		}
+		if !strings.HasPrefix(upper, "CAP_") {
+			upper = "CAP_" + upper
+		}
+		value, ok := capabilities[upper]
+		if !ok {
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// readSeccompProfile 读取并校验seccomp BPF程序文件
+// 文件内容为 struct sock_filter 数组（每条指令8字节，本机字节序）
+func readSeccompProfile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seccomp profile: %v", err)
+	}
+	if len(data) == 0 || len(data)%8 != 0 {
+		return nil, fmt.Errorf("invalid seccomp profile %s: size must be a non-zero multiple of 8", path)
+	}
+	if len(data)/8 > maxSeccompInstructions {
+		return nil, fmt.Errorf("invalid seccomp profile %s: too many instructions", path)
+	}
+	return data, nil
+}
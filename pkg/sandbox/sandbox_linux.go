@@ -0,0 +1,240 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"brick-smart-template/pkg/models"
+
+	"golang.org/x/sys/unix"
+)
+
+// setNamespaces 设置子进程需要新建的命名空间
+func setNamespaces(cmd *exec.Cmd, config *models.SandboxConfig) error {
+	var flags uintptr
+	if config.NewMountNamespace {
+		flags |= syscall.CLONE_NEWNS
+	}
+	if config.NewPIDNamespace {
+		flags |= syscall.CLONE_NEWPID
+	}
+	if config.NewNetworkNamespace {
+		flags |= syscall.CLONE_NEWNET
+	}
+	if flags != 0 {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: flags}
+	}
+	return nil
+}
+
+// Init 沙箱初始化进程入口：应用隔离设置后exec真正的应用，不会返回
+func Init() {
+	// capability、no_new_privs和seccomp都是线程级属性，必须在exec的同一线程上设置
+	runtime.LockOSThread()
+
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+}
+
+// run 按顺序应用沙箱设置并exec应用
+func run() error {
+	var s spec
+	if err := json.Unmarshal([]byte(os.Getenv(specEnv)), &s); err != nil {
+		return fmt.Errorf("invalid sandbox spec: %v", err)
+	}
+	os.Unsetenv(specEnv)
+	if s.Umask != nil {
+		syscall.Umask(*s.Umask)
+	}
+	config := s.Config
+	if config == nil {
+		return syscall.Exec(s.Path, s.Args, os.Environ())
+	}
+
+	// 切换用户前读取seccomp程序，避免权限或只读根影响
+	var filter []byte
+	if config.SeccompProfile != "" {
+		data, err := readSeccompProfile(config.SeccompProfile)
+		if err != nil {
+			return err
+		}
+		filter = data
+	}
+	caps, err := capabilityList(config.DropCapabilities)
+	if err != nil {
+		return err
+	}
+
+	if config.NewMountNamespace {
+		if err := setupMounts(config); err != nil {
+			return err
+		}
+	}
+	if config.NewNetworkNamespace {
+		if err := loopbackUp(); err != nil {
+			return err
+		}
+	}
+
+	// 丢弃bounding集需要CAP_SETPCAP，必须在切换用户前完成
+	if err := dropBoundingCapabilities(caps); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to clear ambient capabilities: %v", err)
+	}
+	if s.Credential != nil {
+		if err := switchCredential(s.Credential); err != nil {
+			return err
+		}
+	}
+	if err := dropCapabilities(caps); err != nil {
+		return err
+	}
+
+	if config.NoNewPrivs {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to set no_new_privs: %v", err)
+		}
+	}
+	if filter != nil {
+		if err := loadSeccomp(filter); err != nil {
+			return err
+		}
+	}
+
+	return syscall.Exec(s.Path, s.Args, os.Environ())
+}
+
+// setupMounts 在新mount命名空间中设置挂载
+func setupMounts(config *models.SandboxConfig) error {
+	// 阻止挂载事件传播回宿主命名空间
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %v", err)
+	}
+	if config.NewPIDNamespace {
+		if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("failed to mount /proc: %v", err)
+		}
+	}
+	if config.ReadOnlyRoot {
+		// 仅根挂载点只读，/proc、/dev等子挂载保持原样
+		if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("failed to remount root read-only: %v", err)
+		}
+	}
+	for _, dir := range config.WritableDirs {
+		if err := unix.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("failed to mount tmpfs on %s: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// loopbackUp 启用新网络命名空间中的loopback接口
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("failed to get loopback flags: %v", err)
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("failed to bring up loopback: %v", err)
+	}
+	return nil
+}
+
+// dropBoundingCapabilities 从bounding集中丢弃capability
+func dropBoundingCapabilities(caps []int) error {
+	for _, c := range caps {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+			// 内核不支持的capability或非root进程（本就没有该capability）时忽略
+			if err == unix.EINVAL || (err == unix.EPERM && os.Geteuid() != 0) {
+				continue
+			}
+			return fmt.Errorf("failed to drop capability %d from bounding set: %v", c, err)
+		}
+	}
+	return nil
+}
+
+// dropCapabilities 从effective/permitted/inheritable集中丢弃capability
+func dropCapabilities(caps []int) error {
+	if len(caps) == 0 {
+		return nil
+	}
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&header, &data[0]); err != nil {
+		return fmt.Errorf("failed to get capabilities: %v", err)
+	}
+	for _, c := range caps {
+		bit := uint32(1) << uint(c%32)
+		data[c/32].Effective &^= bit
+		data[c/32].Permitted &^= bit
+		data[c/32].Inheritable &^= bit
+	}
+	if err := unix.Capset(&header, &data[0]); err != nil {
+		return fmt.Errorf("failed to set capabilities: %v", err)
+	}
+	return nil
+}
+
+// switchCredential 切换到指定的用户和组
+func switchCredential(credential *Credential) error {
+	groups := make([]int, 0, len(credential.Groups))
+	for _, gid := range credential.Groups {
+		groups = append(groups, int(gid))
+	}
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("failed to set groups: %v", err)
+	}
+	if err := syscall.Setgid(int(credential.GID)); err != nil {
+		return fmt.Errorf("failed to set gid: %v", err)
+	}
+	if err := syscall.Setuid(int(credential.UID)); err != nil {
+		return fmt.Errorf("failed to set uid: %v", err)
+	}
+	return nil
+}
+
+// loadSeccomp 加载seccomp BPF过滤器
+func loadSeccomp(data []byte) error {
+	filters := make([]unix.SockFilter, len(data)/8)
+	for i := range filters {
+		chunk := data[i*8 : i*8+8]
+		filters[i] = unix.SockFilter{
+			Code: binary.NativeEndian.Uint16(chunk[0:2]),
+			Jt:   chunk[2],
+			Jf:   chunk[3],
+			K:    binary.NativeEndian.Uint32(chunk[4:8]),
+		}
+	}
+	program := unix.SockFprog{
+		Len:    uint16(len(filters)),
+		Filter: &filters[0],
+	}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&program)), 0, 0); err != nil {
+		return fmt.Errorf("failed to load seccomp profile: %v", err)
+	}
+	return nil
+}
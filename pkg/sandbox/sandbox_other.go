@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"brick-smart-template/pkg/models"
+)
+
+// setNamespaces 非Linux平台不支持沙箱
+func setNamespaces(cmd *exec.Cmd, config *models.SandboxConfig) error {
+	return fmt.Errorf("sandbox is only supported on linux")
+}
+
+// Init 非Linux平台不支持沙箱
+func Init() {
+	fmt.Fprintln(os.Stderr, "sandbox: only supported on linux")
+	os.Exit(126)
+}
@@ -0,0 +1,592 @@
+// Package timeseries 内嵌的有界时序存储，记录状态上报中的数值和字符串字段
+package timeseries
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	// maxBuckets 一次查询最多返回的点数
+	maxBuckets = 10000
+	// defaultQueryRange 未指定 from 时查询的时间范围
+	defaultQueryRange = time.Hour
+	// minCompactRecords 持久化文件的记录数低于该值时不压缩
+	minCompactRecords = 1024
+)
+
+// 聚合方式
+const (
+	AggAvg   = "avg"
+	AggMin   = "min"
+	AggMax   = "max"
+	AggSum   = "sum"
+	AggCount = "count"
+	AggFirst = "first"
+	AggLast  = "last"
+)
+
+// numericAggs 只适用于数值字段的聚合方式
+var numericAggs = map[string]bool{AggAvg: true, AggMin: true, AggMax: true, AggSum: true}
+
+// Config 配置文件中的 history 段
+type Config struct {
+	Path           string        `mapstructure:"path"`             // 持久化文件的绝对路径，为空时只保存在内存中
+	Retention      time.Duration `mapstructure:"retention"`        // 样本保留时长，0表示不按时间淘汰
+	MaxPoints      int           `mapstructure:"max_points"`       // 所有字段合计最多保留的样本数，超出时丢弃最旧的样本
+	MaxFieldPoints int           `mapstructure:"max_field_points"` // 每个字段最多保留的样本数，为0时与 MaxPoints 相同
+	FlushInterval  time.Duration `mapstructure:"flush_interval"`   // 写盘间隔
+}
+
+// Query 历史查询参数
+type Query struct {
+	Field string
+	From  time.Time
+	To    time.Time
+	Step  time.Duration // 为0时返回原始样本
+	Agg   string        // 为空时数值字段取 avg，字符串字段取 last
+}
+
+// sample 一个字段在某一时刻的值
+type sample struct {
+	time   int64 // Unix纳秒
+	field  string
+	number float64
+	text   string
+	isText bool
+}
+
+// series 一个字段的样本
+type series struct {
+	field   string
+	samples []sample // 按时间先后排列
+}
+
+// record 持久化文件中的一行
+type record struct {
+	Time   int64    `json:"t"`
+	Field  string   `json:"f"`
+	Number *float64 `json:"n,omitempty"`
+	Text   *string  `json:"s,omitempty"`
+}
+
+// Store 按字段保存样本，超出保留时长或样本数时丢弃最旧的样本。
+// 新样本定期追加到持久化文件，文件中已淘汰的记录超过一半时重写整个文件。
+type Store struct {
+	mu      sync.RWMutex
+	flushMu sync.Mutex // 串行化写盘，保证记录按时间先后追加
+	config  Config
+	logger  *logrus.Logger
+	series  map[string]*series // 按字段名索引
+	count   int                // 所有字段合计的样本数
+	last    int64              // 最近一个样本的时间
+	pending []sample           // 上次写盘后新增的样本
+	written int                // 持久化文件中的记录数（含已淘汰的样本）
+	compact bool               // 持久化文件缺少部分样本，需要重写
+	resets  uint64             // pending 被丢弃的次数，用于写盘期间检测丢弃
+
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+// Load 从viper配置创建存储，逐项读取以便配置文件只覆盖部分字段时保留其余默认值
+func Load(logger *logrus.Logger) (*Store, error) {
+	return New(Config{
+		Path:           viper.GetString("history.path"),
+		Retention:      viper.GetDuration("history.retention"),
+		MaxPoints:      viper.GetInt("history.max_points"),
+		MaxFieldPoints: viper.GetInt("history.max_field_points"),
+		FlushInterval:  viper.GetDuration("history.flush_interval"),
+	}, logger)
+}
+
+// New 创建存储，持久化文件存在时先加载其中未过期的样本
+func New(config Config, logger *logrus.Logger) (*Store, error) {
+	if config.MaxPoints <= 0 {
+		return nil, fmt.Errorf("history.max_points must be positive")
+	}
+	if config.MaxFieldPoints < 0 {
+		return nil, fmt.Errorf("history.max_field_points must not be negative")
+	}
+	if config.MaxFieldPoints == 0 || config.MaxFieldPoints > config.MaxPoints {
+		config.MaxFieldPoints = config.MaxPoints
+	}
+	if config.Path != "" && !filepath.IsAbs(config.Path) {
+		return nil, fmt.Errorf("history.path must be an absolute path")
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 30 * time.Second
+	}
+
+	s := &Store{
+		config:  config,
+		logger:  logger,
+		series:  make(map[string]*series),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+// Record 记录一次上报中的所有数值和字符串字段，嵌套对象的字段名以 "." 连接
+func (s *Store) Record(t time.Time, data map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 系统时间回拨时沿用上一个样本的时间，保持样本有序
+	ts := t.UnixNano()
+	if ts < s.last {
+		ts = s.last
+	}
+	s.flatten(ts, "", data)
+	s.trim(time.Now())
+}
+
+// flatten 展开嵌套对象，追加样本（调用方需持有锁）
+func (s *Store) flatten(ts int64, prefix string, data map[string]interface{}) {
+	for key, value := range data {
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			s.flatten(ts, field, v)
+		case string:
+			s.add(sample{time: ts, field: field, text: v, isText: true}, true)
+		default:
+			if number, ok := toFloat(v); ok {
+				s.add(sample{time: ts, field: field, number: number}, true)
+			}
+		}
+	}
+}
+
+// add 追加一个样本，persist 为true时记入待写盘的样本（调用方需持有锁）
+func (s *Store) add(sm sample, persist bool) {
+	sr, ok := s.series[sm.field]
+	if !ok {
+		sr = &series{field: sm.field}
+		s.series[sm.field] = sr
+	}
+	// 字段名只保存一份
+	sm.field = sr.field
+	sr.samples = append(sr.samples, sm)
+	s.count++
+	s.last = sm.time
+	if !persist || s.config.Path == "" {
+		return
+	}
+	s.pending = append(s.pending, sm)
+	// 长时间无法写盘时不再积累，写盘恢复后重写整个文件
+	if len(s.pending) > s.config.MaxPoints {
+		s.pending = nil
+		s.compact = true
+		s.resets++
+	}
+}
+
+// trim 按保留时长和样本数丢弃最旧的样本（调用方需持有锁）
+func (s *Store) trim(now time.Time) {
+	cutoff := now.Add(-s.config.Retention).UnixNano()
+	for field, sr := range s.series {
+		drop := 0
+		if s.config.Retention > 0 {
+			drop = sort.Search(len(sr.samples), func(i int) bool { return sr.samples[i].time >= cutoff })
+		}
+		if excess := len(sr.samples) - s.config.MaxFieldPoints; excess > drop {
+			drop = excess
+		}
+		s.drop(field, sr, drop)
+	}
+
+	// 合计样本数超出时，从最旧的样本所在的字段开始丢弃
+	for s.count > s.config.MaxPoints {
+		var oldest *series
+		for _, sr := range s.series {
+			if oldest == nil || sr.samples[0].time < oldest.samples[0].time {
+				oldest = sr
+			}
+		}
+		s.drop(oldest.field, oldest, 1)
+	}
+}
+
+// drop 丢弃一个字段最旧的 n 个样本，字段没有样本时移除（调用方需持有锁）
+func (s *Store) drop(field string, sr *series, n int) {
+	if n == 0 {
+		return
+	}
+	sr.samples = sr.samples[n:]
+	s.count -= n
+	if len(sr.samples) == 0 {
+		delete(s.series, field)
+		return
+	}
+	// 丢弃的样本较多时重新分配，释放底层数组
+	if cap(sr.samples) > 1024 && len(sr.samples) < cap(sr.samples)/2 {
+		sr.samples = append([]sample(nil), sr.samples...)
+	}
+}
+
+// Query 查询一个字段的历史，Step 不为0时按 Step 对齐分桶并聚合
+func (s *Store) Query(q Query) (*models.DataHistory, error) {
+	if q.Field == "" {
+		return nil, models.InvalidRequest("field is required")
+	}
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-defaultQueryRange)
+	}
+	if q.To.Before(q.From) {
+		return nil, models.InvalidRequest("to must not be before from")
+	}
+	if q.Step < 0 {
+		return nil, models.InvalidRequest("step must be positive")
+	}
+	if q.Step > 0 && q.To.Sub(q.From)/q.Step >= maxBuckets {
+		return nil, models.InvalidRequest(fmt.Sprintf("more than %d buckets, use a larger step", maxBuckets))
+	}
+
+	s.mu.RLock()
+	var selected []sample
+	if sr, ok := s.series[q.Field]; ok {
+		from, to := q.From.UnixNano(), q.To.UnixNano()
+		start := sort.Search(len(sr.samples), func(i int) bool { return sr.samples[i].time >= from })
+		end := sort.Search(len(sr.samples), func(i int) bool { return sr.samples[i].time > to })
+		if start < end {
+			selected = append(selected, sr.samples[start:end]...)
+		}
+	}
+	s.mu.RUnlock()
+
+	if q.Agg == "" {
+		q.Agg = AggAvg
+		if n := len(selected); n > 0 && selected[n-1].isText {
+			q.Agg = AggLast
+		}
+	}
+	switch q.Agg {
+	case AggAvg, AggMin, AggMax, AggSum, AggCount, AggFirst, AggLast:
+	default:
+		return nil, models.InvalidRequest(fmt.Sprintf("unsupported agg %q", q.Agg))
+	}
+	if numericAggs[q.Agg] && len(selected) > 0 && !hasNumber(selected) {
+		return nil, models.InvalidRequest(fmt.Sprintf("agg %q requires a numeric field", q.Agg))
+	}
+
+	history := &models.DataHistory{Field: q.Field, From: q.From, To: q.To, Agg: q.Agg, Points: []models.DataPoint{}}
+	if q.Step == 0 {
+		if len(selected) > maxBuckets {
+			return nil, models.InvalidRequest(fmt.Sprintf("more than %d samples, use step to downsample", maxBuckets))
+		}
+		history.Agg = ""
+		for _, sm := range selected {
+			history.Points = append(history.Points, models.DataPoint{Time: time.Unix(0, sm.time), Value: sm.value()})
+		}
+		return history, nil
+	}
+
+	history.Step = q.Step.String()
+	step := int64(q.Step)
+	for i := 0; i < len(selected); {
+		bucket := selected[i].time - selected[i].time%step
+		j := i
+		for j < len(selected) && selected[j].time-selected[j].time%step == bucket {
+			j++
+		}
+		if value, ok := aggregate(q.Agg, selected[i:j]); ok {
+			history.Points = append(history.Points, models.DataPoint{Time: time.Unix(0, bucket), Value: value})
+		}
+		i = j
+	}
+	return history, nil
+}
+
+// hasNumber 是否包含数值样本
+func hasNumber(samples []sample) bool {
+	for _, sm := range samples {
+		if !sm.isText {
+			return true
+		}
+	}
+	return false
+}
+
+// value 样本的值
+func (sm sample) value() interface{} {
+	if sm.isText {
+		return sm.text
+	}
+	return sm.number
+}
+
+// aggregate 聚合一个桶内的样本，数值聚合忽略字符串样本，桶内没有可聚合的样本时返回false
+func aggregate(agg string, samples []sample) (interface{}, bool) {
+	switch agg {
+	case AggCount:
+		return len(samples), true
+	case AggFirst:
+		return samples[0].value(), true
+	case AggLast:
+		return samples[len(samples)-1].value(), true
+	}
+
+	var (
+		count    int
+		sum      float64
+		min, max = math.Inf(1), math.Inf(-1)
+	)
+	for _, sm := range samples {
+		if sm.isText {
+			continue
+		}
+		count++
+		sum += sm.number
+		min = math.Min(min, sm.number)
+		max = math.Max(max, sm.number)
+	}
+	if count == 0 {
+		return nil, false
+	}
+	switch agg {
+	case AggMin:
+		return min, true
+	case AggMax:
+		return max, true
+	case AggSum:
+		return sum, true
+	}
+	return sum / float64(count), true
+}
+
+// toFloat 转换JSON数值
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// run 定期淘汰过期样本并写盘
+func (s *Store) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			s.trim(time.Now())
+			s.mu.Unlock()
+			if err := s.Flush(); err != nil {
+				s.logger.Warnf("Failed to persist data history: %v", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close 停止后台写盘并写入最后的样本
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	<-s.stopped
+	return s.Flush()
+}
+
+// Flush 把新增的样本追加到持久化文件；文件中已淘汰的记录超过一半时改为重写整个文件（先写临时文件再重命名）
+func (s *Store) Flush() error {
+	if s.config.Path == "" {
+		return nil
+	}
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	// 样本追加后不再修改，持有切片即可在锁外写盘
+	n, resets := len(s.pending), s.resets
+	total := s.written + n
+	if !s.compact && (total <= 2*s.count || total < minCompactRecords) {
+		pending := s.pending[:n:n]
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		if err := s.append(pending); err != nil {
+			// 部分写入的记录可能在重试时重复，改为重写整个文件
+			s.mu.Lock()
+			s.compact = true
+			s.mu.Unlock()
+			return err
+		}
+		s.mu.Lock()
+		s.written += n
+		if s.resets == resets {
+			s.pending = s.pending[n:]
+		}
+		s.mu.Unlock()
+		return nil
+	}
+
+	samples := s.snapshot()
+	s.mu.Unlock()
+	if err := s.rewrite(samples); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.written = len(samples)
+	// 写盘期间丢弃过待写样本时，文件仍缺少部分样本
+	if s.resets == resets {
+		s.pending = s.pending[n:]
+		s.compact = false
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// snapshot 按时间先后返回所有字段的样本（调用方需持有锁）
+func (s *Store) snapshot() []sample {
+	samples := make([]sample, 0, s.count)
+	for _, sr := range s.series {
+		samples = append(samples, sr.samples...)
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].time < samples[j].time })
+	return samples
+}
+
+// append 把样本追加到持久化文件，历史中可能有敏感数据，文件和新建的目录只有proxy可以访问
+func (s *Store) append(samples []sample) error {
+	if err := os.MkdirAll(filepath.Dir(s.config.Path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.config.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := encodeSamples(file, samples); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// rewrite 用给定的样本重写持久化文件
+func (s *Store) rewrite(samples []sample) error {
+	if err := os.MkdirAll(filepath.Dir(s.config.Path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.config.Path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := encodeSamples(file, samples); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.config.Path)
+}
+
+// encodeSamples 把样本逐行编码写入文件
+func encodeSamples(file *os.File, samples []sample) error {
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for i := range samples {
+		sm := &samples[i]
+		rec := record{Time: sm.time, Field: sm.field}
+		if sm.isText {
+			rec.Text = &sm.text
+		} else {
+			rec.Number = &sm.number
+		}
+		if err := encoder.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// load 加载持久化文件，忽略无法解析的行
+func (s *Store) load() error {
+	if s.config.Path == "" {
+		return nil
+	}
+	file, err := os.Open(s.config.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	skipped := 0
+	for scanner.Scan() {
+		s.written++
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Field == "" || (rec.Number == nil && rec.Text == nil) {
+			skipped++
+			continue
+		}
+		if rec.Time < s.last {
+			rec.Time = s.last
+		}
+		sm := sample{time: rec.Time, field: rec.Field}
+		if rec.Text != nil {
+			sm.text, sm.isText = *rec.Text, true
+		} else {
+			sm.number = *rec.Number
+		}
+		s.add(sm, false)
+		// 文件中可能有大量已淘汰的记录，加载时及时丢弃
+		if s.count > 2*s.config.MaxPoints {
+			s.trim(time.Now())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read history file: %v", err)
+	}
+	if skipped > 0 {
+		s.logger.Warnf("Skipped %d invalid lines in history file %s", skipped, s.config.Path)
+	}
+	s.trim(time.Now())
+	return nil
+}
@@ -0,0 +1,324 @@
+package timeseries
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"brick-smart-template/pkg/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestStore 创建测试用的存储，测试结束时关闭
+func newTestStore(t *testing.T, config Config) *Store {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	if config.MaxPoints == 0 {
+		config.MaxPoints = 1000
+	}
+	if config.FlushInterval == 0 {
+		config.FlushInterval = time.Hour
+	}
+	store, err := New(config, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// values 返回查询结果中各点的值
+func values(history *models.DataHistory) []interface{} {
+	result := []interface{}{}
+	for _, point := range history.Points {
+		result = append(result, point.Value)
+	}
+	return result
+}
+
+func TestQueryBuckets(t *testing.T) {
+	store := newTestStore(t, Config{})
+	base := time.Now().Truncate(time.Hour).Add(-time.Hour)
+	for i, temp := range []float64{20, 22, 24, 30, 10} {
+		// 0s、20s、40s 落在第一个桶，60s、80s 落在第二个桶
+		store.Record(base.Add(time.Duration(i)*20*time.Second), map[string]interface{}{
+			"sensor": map[string]interface{}{"temp": temp},
+			"mode":   []string{"eco", "eco", "boost", "boost", "off"}[i],
+		})
+	}
+
+	tests := []struct {
+		field string
+		agg   string
+		want  []interface{}
+	}{
+		{"sensor.temp", "", []interface{}{22.0, 20.0}},
+		{"sensor.temp", AggAvg, []interface{}{22.0, 20.0}},
+		{"sensor.temp", AggMin, []interface{}{20.0, 10.0}},
+		{"sensor.temp", AggMax, []interface{}{24.0, 30.0}},
+		{"sensor.temp", AggSum, []interface{}{66.0, 40.0}},
+		{"sensor.temp", AggCount, []interface{}{3, 2}},
+		{"sensor.temp", AggFirst, []interface{}{20.0, 30.0}},
+		{"sensor.temp", AggLast, []interface{}{24.0, 10.0}},
+		{"mode", "", []interface{}{"boost", "off"}},
+		{"mode", AggFirst, []interface{}{"eco", "boost"}},
+		{"missing", AggAvg, []interface{}{}},
+	}
+	for _, tt := range tests {
+		history, err := store.Query(Query{Field: tt.field, From: base, To: base.Add(time.Hour), Step: time.Minute, Agg: tt.agg})
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.field, tt.agg, err)
+		}
+		if got := values(history); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s: got %v, want %v", tt.field, tt.agg, got, tt.want)
+		}
+		if len(history.Points) > 0 && !history.Points[0].Time.Equal(base) {
+			t.Errorf("%s %s: first bucket at %v, want %v", tt.field, tt.agg, history.Points[0].Time, base)
+		}
+	}
+
+	// 不指定 step 时返回原始样本，from/to 为闭区间
+	history, err := store.Query(Query{Field: "sensor.temp", From: base.Add(20 * time.Second), To: base.Add(60 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values(history), []interface{}{22.0, 24.0, 30.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("raw samples: got %v, want %v", got, want)
+	}
+}
+
+func TestQueryValidation(t *testing.T) {
+	store := newTestStore(t, Config{})
+	now := time.Now()
+	store.Record(now, map[string]interface{}{"mode": "eco"})
+
+	tests := []struct {
+		name  string
+		query Query
+	}{
+		{"missing field", Query{}},
+		{"to before from", Query{Field: "mode", From: now, To: now.Add(-time.Second)}},
+		{"negative step", Query{Field: "mode", Step: -time.Second}},
+		{"too many buckets", Query{Field: "mode", From: now.Add(-time.Hour), To: now, Step: time.Millisecond}},
+		{"unknown agg", Query{Field: "mode", Agg: "median"}},
+		{"numeric agg on text", Query{Field: "mode", Step: time.Minute, Agg: AggAvg}},
+	}
+	for _, tt := range tests {
+		if _, err := store.Query(tt.query); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	store := newTestStore(t, Config{Retention: time.Hour})
+	now := time.Now()
+	store.Record(now.Add(-2*time.Hour), map[string]interface{}{"old": 1, "shared": 1})
+	store.Record(now.Add(-30*time.Minute), map[string]interface{}{"shared": 2})
+
+	history, err := store.Query(Query{Field: "shared", From: now.Add(-3 * time.Hour), To: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values(history), []interface{}{2.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shared: got %v, want %v", got, want)
+	}
+	if _, ok := store.series["old"]; ok {
+		t.Errorf("expired field was not removed")
+	}
+	if store.count != 1 {
+		t.Errorf("count = %d, want 1", store.count)
+	}
+}
+
+func TestSampleBounds(t *testing.T) {
+	store := newTestStore(t, Config{MaxPoints: 8, MaxFieldPoints: 5})
+	base := time.Now().Add(-time.Minute)
+	store.Record(base, map[string]interface{}{"quiet": 1})
+	store.Record(base.Add(time.Second), map[string]interface{}{"other": 1})
+	for i := 0; i < 10; i++ {
+		store.Record(base.Add(time.Duration(i+2)*time.Second), map[string]interface{}{"chatty": i})
+	}
+
+	// 频繁上报的字段只保留最近的 5 个样本，不会挤掉其他字段
+	if got := len(store.series["chatty"].samples); got != 5 {
+		t.Errorf("chatty samples = %d, want 5", got)
+	}
+	if _, ok := store.series["quiet"]; !ok {
+		t.Errorf("quiet field was evicted by chatty field")
+	}
+
+	// 合计超出时丢弃所有字段中最旧的样本
+	store.Record(base.Add(20*time.Second), map[string]interface{}{"a": 1, "b": 2})
+	if store.count != 8 {
+		t.Errorf("count = %d, want 8", store.count)
+	}
+	if _, ok := store.series["quiet"]; ok {
+		t.Errorf("oldest sample was not evicted")
+	}
+	if _, ok := store.series["other"]; !ok {
+		t.Errorf("second oldest sample was evicted")
+	}
+}
+
+func TestClockGoingBackwards(t *testing.T) {
+	store := newTestStore(t, Config{})
+	now := time.Now()
+	store.Record(now, map[string]interface{}{"v": 1})
+	store.Record(now.Add(-time.Minute), map[string]interface{}{"v": 2})
+
+	history, err := store.Query(Query{Field: "v", From: now.Add(-time.Hour), To: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values(history), []interface{}{1.0, 2.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// lineCount 返回文件的行数
+func lineCount(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	config := Config{Path: path, MaxPoints: 2000}
+	store := newTestStore(t, config)
+	now := time.Now()
+	store.Record(now.Add(-2*time.Second), map[string]interface{}{"temp": 20.5, "mode": "eco"})
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := lineCount(t, path); got != 2 {
+		t.Fatalf("after first flush: %d lines, want 2", got)
+	}
+
+	// 只追加新增的样本
+	store.Record(now.Add(-time.Second), map[string]interface{}{"temp": 21.5})
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := lineCount(t, path); got != 3 {
+		t.Fatalf("after second flush: %d lines, want 3", got)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newTestStore(t, config)
+	history, err := reloaded.Query(Query{Field: "temp", From: now.Add(-time.Minute), To: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values(history), []interface{}{20.5, 21.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("temp after reload: got %v, want %v", got, want)
+	}
+	if history, _ := reloaded.Query(Query{Field: "mode", From: now.Add(-time.Minute), To: now}); len(history.Points) != 1 {
+		t.Errorf("mode after reload: got %v", values(history))
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := newTestStore(t, Config{Path: path, MaxPoints: 600})
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 1500; i++ {
+		store.Record(base.Add(time.Duration(i)*time.Millisecond), map[string]interface{}{"v": i})
+		if i%100 == 99 {
+			if err := store.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// 文件中已淘汰的记录超过一半时重写，文件不会无限增长
+	lines := lineCount(t, path)
+	if lines > 2*600+100 {
+		t.Errorf("history file has %d lines, expected compaction", lines)
+	}
+	if store.written != lines {
+		t.Errorf("written = %d, file has %d lines", store.written, lines)
+	}
+
+	reloaded := newTestStore(t, Config{Path: path, MaxPoints: 600})
+	history, err := reloaded.Query(Query{Field: "v", From: base, To: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Points) != 600 || history.Points[0].Value != 900.0 {
+		t.Errorf("after reload: %d points starting at %v, want 600 starting at 900", len(history.Points), history.Points[0].Value)
+	}
+}
+
+func TestPersistencePermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	path := filepath.Join(dir, "history.jsonl")
+	store := newTestStore(t, Config{Path: path, MaxPoints: 10})
+	checkModes := func(when string) {
+		t.Helper()
+		for file, want := range map[string]os.FileMode{dir: 0o700, path: 0o600} {
+			info, err := os.Stat(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != want {
+				t.Errorf("%s: %s mode = %o, want %o", when, file, got, want)
+			}
+		}
+	}
+
+	store.Record(time.Now(), map[string]interface{}{"v": 1})
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkModes("append")
+
+	// 重写时新建的文件同样只有proxy可以访问
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	store.mu.Lock()
+	store.compact = true
+	store.mu.Unlock()
+	store.Record(time.Now(), map[string]interface{}{"v": 2})
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkModes("rewrite")
+}
+
+func TestNewValidation(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"zero max points", Config{}},
+		{"negative field points", Config{MaxPoints: 10, MaxFieldPoints: -1}},
+		{"relative path", Config{MaxPoints: 10, Path: "data/history.jsonl"}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.config, logger); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
@@ -159,9 +159,9 @@ main() {
     # echo "  Thermostat:  http://localhost:17103"
     echo ""
     echo "To check status:"
-    echo "  curl http://localhost:17101/app/status"
-    # echo "  curl http://localhost:17102/app/status"
-    # echo "  curl http://localhost:17103/app/status"
+    echo "  curl http://localhost:17101/v1/app/status"
+    # echo "  curl http://localhost:17102/v1/app/status"
+    # echo "  curl http://localhost:17103/v1/app/status"
     echo ""
     echo "To stop all containers:"
     echo "  ./scripts/stop-all.sh"
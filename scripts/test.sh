@@ -53,7 +53,7 @@ start_app() {
     log_info "Configuring $app_name on port $port..."
     
     # 配置app
-    CONFIG_RESPONSE=$(curl -s -X POST http://localhost:$port/app/configure \
+    CONFIG_RESPONSE=$(curl -s -X POST http://localhost:$port/v1/app/configure \
         -H "Content-Type: application/json" \
         -d "{
             \"app_info\": {
@@ -73,7 +73,7 @@ start_app() {
     
     # 启动app
     log_info "Starting $app_name..."
-    START_RESPONSE=$(curl -s -X POST http://localhost:$port/app/start \
+    START_RESPONSE=$(curl -s -X POST http://localhost:$port/v1/app/start?wait=true \
         -H "Content-Type: application/json" \
         -d "{
             \"profile\": \"{\\\"${app_name}_id\\\": \\\"${app_name}-001\\\"}\"
@@ -94,7 +94,7 @@ restart_app() {
     local app_name=$2
     
     log_info "Restarting $app_name on port $port..."
-    RESTART_RESPONSE=$(curl -s -X POST http://localhost:$port/app/restart \
+    RESTART_RESPONSE=$(curl -s -X POST http://localhost:$port/v1/app/restart?wait=true \
         -H "Content-Type: application/json" \
         -d "{
             \"profile\": \"{\\\"${app_name}_id\\\": \\\"${app_name}-001\\\"}\"
@@ -115,7 +115,7 @@ check_app_status() {
     local app_name=$2
     
     log_info "Checking $app_name status on port $port..."
-    STATUS_RESPONSE=$(curl -s http://localhost:$port/app/status 2>/dev/null || echo "{}")
+    STATUS_RESPONSE=$(curl -s http://localhost:$port/v1/app/status 2>/dev/null || echo "{}")
     echo "Status for $app_name: $STATUS_RESPONSE"
     
     if echo "$STATUS_RESPONSE" | grep -q "running\|starting"; then
@@ -136,7 +136,7 @@ stop_app() {
     local app_name=$2
     
     log_info "Stopping $app_name on port $port..."
-    STOP_RESPONSE=$(curl -s -X POST http://localhost:$port/app/stop 2>/dev/null || echo "{}")
+    STOP_RESPONSE=$(curl -s -X POST http://localhost:$port/v1/app/stop?wait=true 2>/dev/null || echo "{}")
     
     if echo "$STOP_RESPONSE" | grep -q "stopped"; then
         log_success "$app_name stopped successfully"
@@ -151,8 +151,8 @@ stop_app() {
 show_status_and_data() {
     local port=$1
     local app_name=$2
-    STATUS_RESPONSE=$(curl -s http://localhost:$port/app/status 2>/dev/null || echo "{}")
-    DATA_RESPONSE=$(curl -s http://localhost:$port/app/data 2>/dev/null || echo "{}")
+    STATUS_RESPONSE=$(curl -s http://localhost:$port/v1/app/status 2>/dev/null || echo "{}")
+    DATA_RESPONSE=$(curl -s http://localhost:$port/v1/app/data 2>/dev/null || echo "{}")
     echo -e "\033[1;36m[STATUS]\033[0m $app_name: $STATUS_RESPONSE"
     echo -e "\033[1;35m[DATA]\033[0m $app_name: $DATA_RESPONSE"
 }
@@ -198,7 +198,7 @@ test_single_app() {
     # 3. 运行状态下每2秒查一次data，持续5次
     log_info "Polling $app_name /app/data every 2s for 5 times while running..."
     for i in {1..5}; do
-        DATA_RESPONSE=$(curl -s http://localhost:$port/app/data 2>/dev/null || echo "{}")
+        DATA_RESPONSE=$(curl -s http://localhost:$port/v1/app/data 2>/dev/null || echo "{}")
         echo -e "\033[1;35m[DATA][$i]\033[0m $app_name: $DATA_RESPONSE"
         sleep 2
     done
@@ -213,13 +213,13 @@ test_single_app() {
 
     # 5. restart app
     log_info "Restarting $app_name after stop..."
-    RESTART_RESPONSE=$(curl -s -X POST http://localhost:$port/app/restart -H "Content-Type: application/json" -d '{}')
+    RESTART_RESPONSE=$(curl -s -X POST http://localhost:$port/v1/app/restart?wait=true -H "Content-Type: application/json" -d '{}')
     sleep 2
     show_status_and_data $port $app_name
 
     # 6. 再查一次data
     log_info "Polling $app_name /app/data once after restart..."
-    DATA_RESPONSE=$(curl -s http://localhost:$port/app/data 2>/dev/null || echo "{}")
+    DATA_RESPONSE=$(curl -s http://localhost:$port/v1/app/data 2>/dev/null || echo "{}")
     echo -e "\033[1;35m[DATA][after-restart]\033[0m $app_name: $DATA_RESPONSE"
 
     echo ""